@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxExtractedSize caps how many bytes extracting one archive writes, so that
+// a corrupt or malicious archive can't fill the disk.
+var maxExtractedSize int64 = 1 << 30
+
+// archives extracts source archives into temporary directories.
+type archives struct {
+	// archive -> where it is extracted
+	dirs map[string]string
+}
+
+func newArchives() archives {
+	return archives{make(map[string]string)}
+}
+
+// extract extracts a .zip, .tar, .tar.gz or .tgz archive, if it isn't already,
+// and returns the directory it is extracted in.
+func (a archives) extract(file string) (string, error) {
+	if dir, ok := a.dirs[file]; ok {
+		return dir, nil
+	}
+	dir, err := os.MkdirTemp("", "refaudit-archive")
+	if err != nil {
+		return "", err
+	}
+	a.dirs[file] = dir
+
+	f, err := os.Open(file)
+	if err != nil {
+		return "", fmt.Errorf("could not read archive %s: %w", file, err)
+	}
+	defer f.Close()
+	switch {
+	case strings.HasSuffix(file, ".zip"):
+		var info os.FileInfo
+		if info, err = f.Stat(); err == nil {
+			err = unzip(f, info.Size(), dir)
+		}
+	case strings.HasSuffix(file, ".tar.gz") || strings.HasSuffix(file, ".tgz"):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(f); err == nil {
+			err = untar(gz, dir)
+		}
+	case strings.HasSuffix(file, ".tar"):
+		err = untar(f, dir)
+	default:
+		return "", fmt.Errorf("unsupported archive %s, expected a .zip, .tar, .tar.gz or .tgz file", file)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not extract archive %s: %w", file, err)
+	}
+	return dir, nil
+}
+
+// cleanup deletes the extracted archives.
+func (a archives) cleanup() {
+	for _, dir := range a.dirs {
+		os.RemoveAll(dir)
+	}
+}
+
+// unzip extracts the directories and regular files in a zip archive of size
+// bytes into dir.
+func unzip(archive io.ReaderAt, size int64, dir string) error {
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return err
+	}
+	remaining := maxExtractedSize
+	for _, f := range zr.File {
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if !within(dir, name) {
+			return fmt.Errorf("%s is outside of the archive", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(name, 0o700); err != nil {
+				return err
+			}
+			continue
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		n, err := extractFile(name, rc, remaining)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// extractFile writes what r reads to name, creating its directory. It fails
+// once more than limit bytes would be written, and returns how many were.
+func extractFile(name string, r io.Reader, limit int64) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o700); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, io.LimitReader(r, limit+1))
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, fmt.Errorf("archive is larger than %d bytes extracted", maxExtractedSize)
+	}
+	return n, f.Close()
+}
+
+// within reports whether path is dir or inside it, so that archive entries
+// like ../../etc/passwd can't be written outside of where they are extracted.
+func within(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
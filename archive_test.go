@@ -0,0 +1,62 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithin(t *testing.T) {
+	dir := filepath.Join("tmp", "extract")
+	assert.True(t, within(dir, dir))
+	assert.True(t, within(dir, filepath.Join(dir, "a", "b.go")))
+	assert.True(t, within(dir, filepath.Join(dir, "..extract", "b.go")), "only a whole .. element leaves")
+	assert.False(t, within(dir, filepath.Join(dir, "..", "b.go")))
+	assert.False(t, within(dir, filepath.Join("tmp", "extract2", "b.go")))
+}
+
+func TestExtractLimits(t *testing.T) {
+	zipped := func(name string, content string) *bytes.Reader {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return bytes.NewReader(buf.Bytes())
+	}
+	tarred := func(name string, content string) *bytes.Reader {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0o600, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, tw.Close())
+		return bytes.NewReader(buf.Bytes())
+	}
+
+	dir := t.TempDir()
+	z := zipped("lib/lib.go", "package lib\n")
+	require.NoError(t, unzip(z, z.Size(), dir))
+	b, err := os.ReadFile(filepath.Join(dir, "lib", "lib.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package lib\n", string(b))
+	require.NoError(t, untar(tarred("lib/lib.go", "package lib\n"), t.TempDir()))
+
+	z = zipped("../evil.go", "package evil\n")
+	assert.Error(t, unzip(z, z.Size(), t.TempDir()))
+	assert.Error(t, untar(tarred("../evil.go", "package evil\n"), t.TempDir()))
+
+	defer func(max int64) { maxExtractedSize = max }(maxExtractedSize)
+	maxExtractedSize = 4
+	z = zipped("big.go", "package big\n")
+	assert.Error(t, unzip(z, z.Size(), t.TempDir()))
+	assert.Error(t, untar(tarred("big.go", "package big\n"), t.TempDir()))
+}
@@ -0,0 +1,194 @@
+// Package audit runs a syntax-only audit of the exports in some directories
+// against the references in others, for programs that drive refaudit
+// themselves:
+//
+//	events := make(chan audit.Event, 100)
+//	go func() {
+//		for e := range events {
+//			...
+//		}
+//	}()
+//	result, err := audit.Run(ctx, audit.Options{From: from, To: to, Events: events})
+package audit
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// Options configures an audit.
+type Options struct {
+	// From are the directories to find exports in, including subdirectories.
+	From []string
+	// To are the directories to find references in, including subdirectories.
+	To []string
+	// Fields also audits the exported fields of exported structs.
+	Fields bool
+	// InterfaceMethods also audits the methods of exported interfaces, one by
+	// one. Syntax alone only credits method expressions, like pkg.Iface.Method,
+	// so most will look unused.
+	InterfaceMethods bool
+	// Events, if set, receives an Event as the audit progresses, and is closed
+	// when it is done. Sending never blocks the audit: when the channel's buffer
+	// is full, events other than Done are dropped, so give it a buffer if you
+	// need all of them. Done is always sent, unless ctx is cancelled first.
+	Events chan<- Event
+}
+
+// Result is what an audit found. The lists are sorted.
+type Result struct {
+	Exported      []string
+	Imported      []string
+	UnusedExports []string
+}
+
+// Event is one of FileScanned, PackageLoaded, ExportFound, UnusedFound or Done.
+type Event interface {
+	event()
+}
+
+// FileScanned is sent after a file has been scanned for exports or references.
+type FileScanned struct {
+	File string
+}
+
+// PackageLoaded is sent after a package has been loaded, before its files are
+// scanned for exports.
+type PackageLoaded struct {
+	Path string
+}
+
+// ExportFound is sent for every export.
+type ExportFound struct {
+	Symbol   string
+	Kind     symbols.Kind
+	Position token.Position
+}
+
+// UnusedFound is sent for every unused export, once all the references are
+// found.
+type UnusedFound struct {
+	Symbol string
+}
+
+// Done is the last event, with the audit's result or error.
+type Done struct {
+	Result Result
+	Err    error
+}
+
+func (FileScanned) event()   {}
+func (PackageLoaded) event() {}
+func (ExportFound) event()   {}
+func (UnusedFound) event()   {}
+func (Done) event()          {}
+
+// Run finds the exports in opts.From that nothing in opts.To references.
+func Run(ctx context.Context, opts Options) (Result, error) {
+	result, err := run(ctx, opts)
+	if opts.Events != nil {
+		select {
+		case opts.Events <- Done{result, err}:
+		case <-ctx.Done():
+		}
+		close(opts.Events)
+	}
+	return result, err
+}
+
+func run(ctx context.Context, opts Options) (Result, error) {
+	emit := func(e Event) {
+		select {
+		case opts.Events <- e:
+		default:
+		}
+	}
+	if opts.Events == nil {
+		emit = func(Event) {}
+	}
+
+	result := Result{Exported: []string{}, Imported: []string{}, UnusedExports: []string{}}
+	exports := make(map[string]symbols.Export)
+	for _, dir := range opts.From {
+		fset := token.NewFileSet()
+		pkgs, err := packages.Load(&packages.Config{
+			Context: ctx,
+			Dir:     dir,
+			Fset:    fset,
+			Mode:    packages.NeedName | packages.NeedFiles | packages.NeedSyntax,
+		}, "./...")
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to load packages in %s: %w", dir, err)
+		}
+		for _, pkg := range pkgs {
+			emit(PackageLoaded{pkg.PkgPath})
+			for _, f := range pkg.Syntax {
+				for symbol, export := range symbols.FindExports(f, pkg.PkgPath) {
+					if export.Kind == symbols.Field && !opts.Fields {
+						continue
+					}
+					if export.Kind == symbols.InterfaceMethod && !opts.InterfaceMethods {
+						continue
+					}
+					exports[symbol] = export
+					emit(ExportFound{symbol, export.Kind, fset.Position(export.Pos)})
+				}
+				emit(FileScanned{fset.Position(f.Pos()).Filename})
+			}
+		}
+	}
+
+	refs := make(map[string]interface{})
+	for _, dir := range opts.To {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(path, ".go") {
+				return nil
+			}
+			f, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.AllErrors)
+			if err != nil {
+				return fmt.Errorf("could not parse %s: %w", path, err)
+			}
+			for symbol := range symbols.FindRefs(f) {
+				refs[symbol] = struct{}{}
+			}
+			emit(FileScanned{path})
+			return nil
+		})
+		if err != nil {
+			return Result{}, fmt.Errorf("failed to find references in %s: %w", dir, err)
+		}
+	}
+
+	for symbol := range exports {
+		result.Exported = append(result.Exported, symbol)
+		if _, ok := refs[symbol]; !ok {
+			result.UnusedExports = append(result.UnusedExports, symbol)
+		}
+	}
+	for symbol := range refs {
+		result.Imported = append(result.Imported, symbol)
+	}
+	sort.Strings(result.Exported)
+	sort.Strings(result.Imported)
+	sort.Strings(result.UnusedExports)
+	for _, symbol := range result.UnusedExports {
+		emit(UnusedFound{symbol})
+	}
+	return result, nil
+}
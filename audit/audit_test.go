@@ -0,0 +1,44 @@
+package audit
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+func TestEvents(t *testing.T) {
+	lib, err := filepath.Abs("../testdata/simulate/lib")
+	require.NoError(t, err)
+	web, err := filepath.Abs("../testdata/simulate/web")
+	require.NoError(t, err)
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/simulate/lib"
+
+	events := make(chan Event, 100)
+	result, err := Run(context.TODO(), Options{From: []string{lib}, To: []string{web}, Events: events})
+	require.NoError(t, err)
+	assert.Equal(t, []string{pkg + ".Solo"}, result.UnusedExports)
+
+	collected := []Event{}
+	for e := range events {
+		collected = append(collected, e)
+	}
+	require.Len(t, collected, 7)
+	assert.Equal(t, PackageLoaded{pkg}, collected[0])
+	found := []string{}
+	for _, e := range collected[1:3] {
+		export := e.(ExportFound)
+		assert.Equal(t, symbols.Func, export.Kind)
+		assert.Equal(t, filepath.Join(lib, "lib.go"), export.Position.Filename)
+		found = append(found, export.Symbol)
+	}
+	assert.ElementsMatch(t, []string{pkg + ".Shared", pkg + ".Solo"}, found)
+	assert.Equal(t, FileScanned{filepath.Join(lib, "lib.go")}, collected[3])
+	assert.Equal(t, FileScanned{filepath.Join(web, "web.go")}, collected[4])
+	assert.Equal(t, UnusedFound{pkg + ".Solo"}, collected[5])
+	assert.Equal(t, Done{Result: result}, collected[6])
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// baselineFormats are the supported --baseline-format values.
+var baselineFormats = []string{"json", "csv", "list"}
+
+// BaselineDiff compares the unused exports with the ones in a baseline.
+type BaselineDiff struct {
+	// NewUnused lists exports that are unused now, but weren't in the baseline.
+	NewUnused []string
+	// Resolved lists exports that were unused in the baseline, but aren't now.
+	Resolved []string
+}
+
+// loadBaseline reads the unused exports from a baseline file. A json baseline
+// is a previous report. A csv baseline has a header row, and symbols in the
+// "symbol" column, or the first column if there isn't one. A list baseline has
+// one symbol per line, ignoring blank lines and lines starting with #.
+func loadBaseline(file string, format string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read baseline %s: %w", file, err)
+	}
+
+	unused := make(map[string]interface{})
+	switch format {
+	case "json":
+		rpt := Report{}
+		if err := json.Unmarshal(b, &rpt); err != nil {
+			return nil, fmt.Errorf("could not parse baseline %s: %w", file, err)
+		}
+		for _, symbol := range rpt.UnusedExports {
+			unused[symbol] = exists
+		}
+	case "csv":
+		records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("could not parse baseline %s: %w", file, err)
+		}
+		if len(records) == 0 {
+			break
+		}
+		col := 0
+		for i, name := range records[0] {
+			if strings.EqualFold(strings.TrimSpace(name), "symbol") {
+				col = i
+			}
+		}
+		for _, record := range records[1:] {
+			if col < len(record) && strings.TrimSpace(record[col]) != "" {
+				unused[strings.TrimSpace(record[col])] = exists
+			}
+		}
+	case "list":
+		if unused, err = parseList(b); err != nil {
+			return nil, fmt.Errorf("could not read baseline %s: %w", file, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported baseline format %s, expected one of %s", format, strings.Join(baselineFormats, ", "))
+	}
+	return unused, nil
+}
+
+// parseList reads one symbol per line, ignoring blank lines and lines starting
+// with #.
+func parseList(b []byte) (map[string]interface{}, error) {
+	list := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			list[line] = exists
+		}
+	}
+	return list, scanner.Err()
+}
+
+// loadBaselinePositions reads where the unused exports were declared from a
+// json baseline, which must have been written with --positions.
+func loadBaselinePositions(file string) (map[string]Position, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read baseline %s: %w", file, err)
+	}
+	rpt := Report{}
+	if err := json.Unmarshal(b, &rpt); err != nil {
+		return nil, fmt.Errorf("could not parse baseline %s: %w", file, err)
+	}
+	if len(rpt.UnusedExports) > 0 && len(rpt.Positions) == 0 {
+		return nil, fmt.Errorf("baseline %s has no positions, write it with %s", file, positionsArg)
+	}
+	return rpt.Positions, nil
+}
+
+// diffBaseline compares the currently unused exports with a baseline, by
+// symbol only, so exports that moved are the same as before.
+func diffBaseline(baseline map[string]interface{}, unused []string) *BaselineDiff {
+	newUnused, resolved := diffSymbols(baseline, unused)
+	return &BaselineDiff{NewUnused: newUnused, Resolved: resolved}
+}
+
+// diffBaselinePositions compares the currently unused exports with a baseline
+// by symbol and position, so an export that moved is both new and resolved.
+func diffBaselinePositions(baseline map[string]interface{}, previous map[string]Position, unused []string, current map[string]Position) *BaselineDiff {
+	diff := diffBaseline(baseline, unused)
+	for _, symbol := range unused {
+		if _, ok := baseline[symbol]; ok && previous[symbol] != current[symbol] {
+			diff.NewUnused = sortedInsert(diff.NewUnused, symbol)
+			diff.Resolved = sortedInsert(diff.Resolved, symbol)
+		}
+	}
+	return diff
+}
+
+// diffSymbols returns the symbols in current that aren't in previous, and the
+// symbols in previous that aren't in current, both sorted.
+func diffSymbols(previous map[string]interface{}, current []string) ([]string, []string) {
+	added, removed := []string{}, []string{}
+	seen := make(map[string]interface{}, len(current))
+	for _, symbol := range current {
+		seen[symbol] = exists
+		if _, ok := previous[symbol]; !ok {
+			added = sortedInsert(added, symbol)
+		}
+	}
+	for symbol := range previous {
+		if _, ok := seen[symbol]; !ok {
+			removed = sortedInsert(removed, symbol)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// config is what the command line asks for, as given. Values are checked by
+// validate, after the usage is printed if there is nothing to audit.
+type config struct {
+	from                 []string
+	excludeFrom          []string
+	to                   []string
+	excludeTo            []string
+	packagesErrors       bool
+	strict               bool
+	countByConsumer      bool
+	precise              bool
+	groupBy              string
+	excludeEmptyPackages bool
+	templateFile         string
+	exportsSince         string
+	assertMessage        string
+	scanDocExamples      bool
+	baselineFile         string
+	baselineFormat       string
+	baselinePositions    bool
+	jsonSchema           bool
+	withUsages           bool
+	failOnKind           string
+	sinceTag             string
+	moduleRelative       bool
+	surface              bool
+	summaryOnly          bool
+	includeTests         bool
+	trendFile            string
+	testOnlyExports      bool
+	sortBy               string
+	excludeModules       []string
+	verify               bool
+	simulateRemove       string
+	maxDepth             string
+	excludeGlobs         []string
+	exitCodeCount        bool
+	singleConsumer       bool
+	withReferencedBy     bool
+	preciseKinds         string
+	facades              []string
+	fields               bool
+	interfaceGranularity string
+	profilePackages      bool
+	stdinPath            string
+	fromStdin, toStdin   bool
+	apiSpecFile          string
+	enforceSpec          bool
+	fromArchives         []string
+	toArchives           []string
+	gitAge               bool
+	positions            bool
+	format               string
+	fix                  bool
+	failOnUnused         bool
+	toDeps               []string
+	packagesJSON         []string
+	generatedOnly        bool
+	jobs                 string
+	listPkgs             bool
+	refManifests         []string
+	refRegexes           []string
+	// consumer group -> its to directories
+	consumerGroups   map[string][]string
+	requiredGroup    string
+	manifestKey      string
+	blankImports     bool
+	maxFileSize      string
+	testRefs         string
+	tags             buildTags
+	excludeFileRegex string
+	sink             string
+	sinkTimeout      string
+	sinkRetries      string
+}
+
+// parseArgs reads the flags in args into a config. Warnings about deprecated
+// flags are written to stderr. It only fails if a path list can't be read.
+func parseArgs(args []string, stderr io.Writer) (config, error) {
+	c := config{
+		from:                 []string{},
+		excludeFrom:          []string{},
+		to:                   []string{},
+		excludeTo:            []string{},
+		baselineFormat:       "json",
+		sortBy:               "symbol",
+		excludeModules:       []string{},
+		excludeGlobs:         []string{},
+		facades:              []string{},
+		interfaceGranularity: "type",
+		fromArchives:         []string{},
+		toArchives:           []string{},
+		format:               "json",
+		toDeps:               []string{},
+		packagesJSON:         []string{},
+		jobs:                 strconv.Itoa(runtime.NumCPU()),
+		refManifests:         []string{},
+		refRegexes:           []string{},
+		consumerGroups:       make(map[string][]string),
+		testRefs:             "include",
+		tags:                 buildTags{},
+		sinkTimeout:          "30s",
+		sinkRetries:          "2",
+	}
+	// the directories of the last --to, which --to-tags applies to
+	lastTo := []string{}
+	// the first path list that couldn't be read
+	var pathsErr error
+	paths := func(arg string) []string {
+		expanded, err := expandPaths(arg)
+		if err != nil && pathsErr == nil {
+			pathsErr = err
+		}
+		return expanded
+	}
+	addArg := func(arg string) {}
+	for _, a := range args {
+		switch a {
+		case packagesErrorsArg:
+			c.packagesErrors = true
+		case strictArg:
+			c.strict = true
+		case countByConsumerArg:
+			c.countByConsumer = true
+		case preciseArg:
+			c.precise = true
+		case excludeEmptyPackagesArg:
+			c.excludeEmptyPackages = true
+		case scanDocExamplesArg:
+			c.scanDocExamples = true
+		case moduleRelativeArg:
+			c.moduleRelative = true
+		case surfaceHashArg:
+			c.surface = true
+		case summaryOnlyArg:
+			c.summaryOnly = true
+		case testExportsArg:
+			c.includeTests = true
+		case includeTestsArg:
+			fmt.Fprintf(stderr, "%s is deprecated, use %s\n", includeTestsArg, testExportsArg)
+			c.includeTests = true
+		case testOnlyExportsArg:
+			c.testOnlyExports = true
+		case generatedOnlyUsageArg:
+			c.generatedOnly = true
+		case listPackagesArg:
+			c.listPkgs = true
+		case blankImportsArg:
+			c.blankImports = true
+		case verifyArg:
+			c.verify = true
+		case exitCodeCountArg:
+			c.exitCodeCount = true
+		case singleConsumerArg:
+			c.singleConsumer = true
+		case referencedByArg:
+			c.withReferencedBy = true
+		case fieldsArg:
+			c.fields = true
+		case profilePackagesArg:
+			c.profilePackages = true
+		case stdinPathArg:
+			addArg = func(arg string) { c.stdinPath = arg }
+		case interfaceGranularityArg:
+			addArg = func(arg string) { c.interfaceGranularity = arg }
+		case gitAgeArg:
+			c.gitAge = true
+		case positionsArg:
+			c.positions = true
+		case fixArg:
+			c.fix = true
+		case failOnUnusedArg:
+			c.failOnUnused = true
+		case enforceSpecArg:
+			c.enforceSpec = true
+		case groupByArg:
+			addArg = func(arg string) { c.groupBy = arg }
+		case templateArg:
+			addArg = func(arg string) { c.templateFile = expandPath(arg) }
+		case exportsSinceArg:
+			addArg = func(arg string) { c.exportsSince = arg }
+		case baselineArg:
+			addArg = func(arg string) { c.baselineFile = expandPath(arg) }
+		case withUsagesArg:
+			c.withUsages = true
+		case jsonSchemaArg:
+			c.jsonSchema = true
+		case baselinePositionsArg:
+			c.baselinePositions = true
+		case baselineFormatArg:
+			addArg = func(arg string) { c.baselineFormat = arg }
+		case failOnKindArg:
+			addArg = func(arg string) { c.failOnKind = arg }
+		case sinceTagArg:
+			addArg = func(arg string) { c.sinceTag = arg }
+		case sinkArg:
+			addArg = func(arg string) { c.sink = arg }
+		case sinkTimeoutArg:
+			addArg = func(arg string) { c.sinkTimeout = arg }
+		case sinkRetriesArg:
+			addArg = func(arg string) { c.sinkRetries = arg }
+		case excludeFileRegexArg:
+			addArg = func(arg string) { c.excludeFileRegex = arg }
+		case trendFileArg:
+			addArg = func(arg string) { c.trendFile = expandPath(arg) }
+		case sortArg:
+			addArg = func(arg string) { c.sortBy = arg }
+		case excludeModuleArg:
+			addArg = func(arg string) { c.excludeModules = append(c.excludeModules, arg) }
+		case fromArchiveArg:
+			addArg = func(arg string) { c.fromArchives = append(c.fromArchives, expandPath(arg)) }
+		case toArchiveArg:
+			addArg = func(arg string) { c.toArchives = append(c.toArchives, expandPath(arg)) }
+		case toDepsArg:
+			addArg = func(arg string) { c.toDeps = append(c.toDeps, arg) }
+		case packagesJSONArg:
+			addArg = func(arg string) { c.packagesJSON = append(c.packagesJSON, expandPath(arg)) }
+		case formatArg:
+			addArg = func(arg string) { c.format = arg }
+		case apiSpecArg:
+			addArg = func(arg string) { c.apiSpecFile = expandPath(arg) }
+		case facadeArg:
+			addArg = func(arg string) { c.facades = append(c.facades, expandPath(arg)) }
+		case preciseKindsArg:
+			addArg = func(arg string) { c.preciseKinds = arg }
+		case refManifestArg:
+			addArg = func(arg string) { c.refManifests = append(c.refManifests, arg) }
+		case refRegexArg:
+			addArg = func(arg string) { c.refRegexes = append(c.refRegexes, arg) }
+		case manifestKeyArg:
+			addArg = func(arg string) { c.manifestKey = arg }
+		case jobsArg:
+			addArg = func(arg string) { c.jobs = arg }
+		case maxFileSizeArg:
+			addArg = func(arg string) { c.maxFileSize = arg }
+		case maxDepthArg:
+			addArg = func(arg string) { c.maxDepth = arg }
+		case excludeGlobArg:
+			addArg = func(arg string) { c.excludeGlobs = append(c.excludeGlobs, arg) }
+		case simulateRemoveArg:
+			addArg = func(arg string) { c.simulateRemove = expandPath(arg) }
+		case assertArg:
+			addArg = func(arg string) { c.assertMessage = arg }
+		case fromArg:
+			addArg = func(arg string) {
+				if arg == stdinArg {
+					c.fromStdin = true
+					return
+				}
+				c.from = append(c.from, paths(arg)...)
+			}
+		case excludeFromArg:
+			addArg = func(arg string) { c.excludeFrom = append(c.excludeFrom, paths(arg)...) }
+		case toArg:
+			lastTo = []string{}
+			addArg = func(arg string) {
+				if arg == stdinArg {
+					c.toStdin = true
+					return
+				}
+				expanded := paths(arg)
+				c.to = append(c.to, expanded...)
+				lastTo = append(lastTo, expanded...)
+			}
+		case testRefsArg:
+			addArg = func(arg string) { c.testRefs = arg }
+		case buildTagsArg:
+			addArg = func(arg string) { c.tags[""] = append(c.tags[""], strings.Split(arg, ",")...) }
+		case toTagsArg:
+			addArg = func(arg string) {
+				for _, dir := range lastTo {
+					c.tags[dir] = append(c.tags[dir], strings.Split(arg, ",")...)
+				}
+			}
+		case toGroupArg:
+			addArg = func(arg string) { c.consumerGroups[arg] = append(c.consumerGroups[arg], lastTo...) }
+		case requireConsumerGroupArg:
+			addArg = func(arg string) { c.requiredGroup = arg }
+		case excludeToArg:
+			addArg = func(arg string) { c.excludeTo = append(c.excludeTo, paths(arg)...) }
+		default:
+			addArg(a)
+		}
+	}
+	return c, pathsErr
+}
+
+// validate checks that the values of the flags are supported and go together.
+func (c config) validate() error {
+	if c.groupBy != "" && c.groupBy != "package" && c.groupBy != "module" {
+		return fmt.Errorf("unsupported %s value: %s", groupByArg, c.groupBy)
+	}
+	if c.sortBy != "symbol" && c.sortBy != "location" {
+		return fmt.Errorf("unsupported %s value: %s", sortArg, c.sortBy)
+	}
+	if c.format != "json" && c.format != "text" && c.format != "csv" && c.format != "list" {
+		return fmt.Errorf("unsupported %s value: %s", formatArg, c.format)
+	}
+	if c.format != "json" && (c.templateFile != "" || c.summaryOnly) {
+		return fmt.Errorf("%s %s can't be combined with %s or %s", formatArg, c.format, templateArg, summaryOnlyArg)
+	}
+	if c.interfaceGranularity != "type" && c.interfaceGranularity != "method" {
+		return fmt.Errorf("unsupported %s value: %s", interfaceGranularityArg, c.interfaceGranularity)
+	}
+	if c.testRefs != "include" && c.testRefs != "exclude" && c.testRefs != "separate" {
+		return fmt.Errorf("unsupported %s value: %s", testRefsArg, c.testRefs)
+	}
+	if _, ok := c.consumerGroups[c.requiredGroup]; c.requiredGroup != "" && !ok {
+		return fmt.Errorf("unknown %s value: %s isn't named with %s", requireConsumerGroupArg, c.requiredGroup, toGroupArg)
+	}
+	if c.fromStdin && c.stdinPath == "" {
+		return fmt.Errorf("%s %s needs %s", fromArg, stdinArg, stdinPathArg)
+	}
+	if c.baselinePositions && (c.baselineFile == "" || c.baselineFormat != "json") {
+		return fmt.Errorf("%s requires a json %s", baselinePositionsArg, baselineArg)
+	}
+	if c.enforceSpec && c.apiSpecFile == "" {
+		return fmt.Errorf("%s requires %s", enforceSpecArg, apiSpecArg)
+	}
+	return nil
+}
+
+// writeUsage writes how to use refaudit, with every flag.
+func writeUsage(w io.Writer) {
+	fmt.Fprintln(w, "Find potentially unused exports in go code. Works across repos. There will be false positives.")
+	fmt.Fprintf(w, "Usage:\n\trefaudit %s [files] %s [files]\n", fromArg, toArg)
+	fmt.Fprintf(w, "\trefaudit %s\n", doctorCmd)
+	fmt.Fprintf(w, "%s: Check that the go toolchain can load and resolve packages, before an audit.\n", doctorCmd)
+	fmt.Fprintf(w, "%s: Print the version, commit and build date of refaudit, and exit.\n", versionArg)
+	fmt.Fprintln(w, "Paths for the directory flags below can also be listed in a file, one per line, passed as @file. Blank lines and lines starting with # are ignored.")
+	fmt.Fprintf(w, "%s: Directories that contain exports. %s reads a single file from stdin, with %s.\n", fromArg, stdinArg, stdinPathArg)
+	fmt.Fprintf(w, "%s: The import path of the package the file read from stdin belongs to. Required with %s %s.\n", stdinPathArg, fromArg, stdinArg)
+	fmt.Fprintf(w, "%s: Directories that contain imports. %s reads a single file from stdin.\n", toArg, stdinArg)
+	fmt.Fprintf(w, "%s: Directories that contain imports that you want to exclude, with everything under them. Optional.\n", excludeToArg)
+	fmt.Fprintf(w, "%s: Name of a consumer group to add the directories of the %s before it to. Optional.\n", toGroupArg, toArg)
+	fmt.Fprintf(w, "%s: Exit with %d if any export isn't referenced by this %s. Optional.\n", requireConsumerGroupArg, orphanExitCode, toGroupArg)
+	fmt.Fprintf(w, "%s: Comma separated build tags for the directories of the %s before it. Files are only read if their build constraints are satisfied. Optional.\n", toTagsArg, toArg)
+	fmt.Fprintf(w, "%s: Directories that contain exports that you want to exclude, with everything under them. Optional.\n", excludeFromArg)
+	fmt.Fprintf(w, "%s: Also find exports in test files in the %s directories, attributed to the test package they are in. Unlike %s, this changes what is audited, not what counts as a reference. Formerly %s. Optional.\n", testExportsArg, fromArg, testRefsArg, includeTestsArg)
+	fmt.Fprintf(w, "%s: Module paths to skip entirely, when looking for both exports and imports. Optional.\n", excludeModuleArg)
+	fmt.Fprintf(w, "%s: Ignore exports in files whose path matches this regular expression. Optional.\n", excludeFileRegexArg)
+	fmt.Fprintf(w, "%s: Include errors reported while loading packages in the output. Optional.\n", packagesErrorsArg)
+	fmt.Fprintf(w, "%s: Fail if any package could not be loaded cleanly, including type errors in %s packages with %s. Optional.\n", strictArg, toArg, preciseArg)
+	fmt.Fprintf(w, "%s: Count how many exports each consumer package references. Optional.\n", countByConsumerArg)
+	fmt.Fprintf(w, "%s: Type-check imports to credit methods called directly or through interfaces. Slower. Optional.\n", preciseArg)
+	fmt.Fprintf(w, "%s: Also group exports in the output. Supported: package, module. Optional.\n", groupByArg)
+	fmt.Fprintf(w, "%s: Don't load or report packages that export nothing. Optional.\n", excludeEmptyPackagesArg)
+	fmt.Fprintf(w, "%s: Render the report with a go text/template file instead of JSON. See readme.md for fields. Optional.\n", templateArg)
+	fmt.Fprintf(w, "%s: Only audit exports declared on lines added since this git ref. Optional.\n", exportsSinceArg)
+	fmt.Fprintf(w, "%s: Instead of the report, print this message and the unused exports, exiting with %d if there are any. Optional.\n", assertArg, unusedExitCode)
+	fmt.Fprintf(w, "%s: Credit exports used in Example functions and code blocks in comments in %s. Optional.\n", scanDocExamplesArg, fromArg)
+	fmt.Fprintf(w, "%s: Compare unused exports with those in this file from a previous run. Optional.\n", baselineArg)
+	fmt.Fprintf(w, "%s: Format of the %s file, one of %s. Defaults to json. Optional.\n", baselineFormatArg, baselineArg, strings.Join(baselineFormats, ", "))
+	fmt.Fprintf(w, "%s: Comma separated kinds of unused exports to exit with %d for, out of %s. Optional.\n", failOnKindArg, unusedExitCode, kindList(symbols.Kinds))
+	fmt.Fprintf(w, "%s: Compare exports and unused exports with the %s directories at this git tag. Optional.\n", sinceTagArg, fromArg)
+	fmt.Fprintf(w, "%s: Shorten symbols from the %s modules to be relative to their module. Optional.\n", moduleRelativeArg, fromArg)
+	fmt.Fprintf(w, "%s: Also POST the JSON report to this HTTP URL. Optional.\n", sinkArg)
+	fmt.Fprintf(w, "%s: Timeout for each %s request. Defaults to 30s. Optional.\n", sinkTimeoutArg, sinkArg)
+	fmt.Fprintf(w, "%s: How many times to retry a failed %s request. Defaults to 2. Optional.\n", sinkRetriesArg, sinkArg)
+	fmt.Fprintf(w, "%s: Include a hash of the exports and their kinds, to detect changes to them. Optional.\n", surfaceHashArg)
+	fmt.Fprintf(w, "%s: Only print the number of exported, referenced and unused exports, as compact JSON. Optional.\n", summaryOnlyArg)
+	fmt.Fprintf(w, "%s: Append a line of JSON with the time and export counts to this file. Optional.\n", trendFileArg)
+	fmt.Fprintf(w, "%s: List unused exports that are referenced by their own package's tests. Optional.\n", testOnlyExportsArg)
+	fmt.Fprintf(w, "%s: Order unused exports by symbol, or by location to go through them file by file. Defaults to symbol. Optional.\n", sortArg)
+	fmt.Fprintf(w, "%s: Move unused exports whose name appears anywhere in the imports' text to NeedsReview. Slower. Optional.\n", verifyArg)
+	fmt.Fprintf(w, "%s: A consumer directory to report the exports only it uses, as if it was removed. Optional.\n", simulateRemoveArg)
+	fmt.Fprintf(w, "%s: Patterns of file names to skip, as in filepath.Match, like '*_gen.go' 'mock_*.go'. Combines with the exclude directories. Optional.\n", excludeGlobArg)
+	fmt.Fprintf(w, "%s: How many directory levels below each path to walk, for a quick scan. Deeper packages are missed. Optional.\n", maxDepthArg)
+	fmt.Fprintf(w, "%s: Exit with %d plus the number of unused exports, up to %d, or 0 if there are none. Optional.\n", exitCodeCountArg, countExitCodeBase, maxExitCode)
+	fmt.Fprintf(w, "%s: List the exports that only one consumer package references. Optional.\n", singleConsumerArg)
+	fmt.Fprintf(w, "%s: List the consumer packages that reference each export. Optional.\n", referencedByArg)
+	fmt.Fprintf(w, "%s: Kinds of exports for %s to resolve, comma separated, to make it faster. Supported: %s. Optional.\n", preciseKindsArg, preciseArg, kindList(symbols.Kinds))
+	fmt.Fprintf(w, "%s: Directories of facade packages, whose references to the exports count as uses. Optional.\n", facadeArg)
+	fmt.Fprintf(w, "%s: Also audit the exported fields of exported structs, as Type.Field. Optional.\n", fieldsArg)
+	fmt.Fprintf(w, "%s: Whether to audit exported interfaces by type, or each of their methods too, as Type.Method. Without %s, calls of a method by the same name are credited. Supported: type, method. Defaults to type. Optional.\n", interfaceGranularityArg, preciseArg)
+	fmt.Fprintf(w, "%s: A file listing the intended exports, one per line, to compare the exports with. Optional.\n", apiSpecArg)
+	fmt.Fprintf(w, "%s: Exit with %d if the exports don't match %s. Optional.\n", enforceSpecArg, specExitCode, apiSpecArg)
+	fmt.Fprintf(w, "%s: Source archives to find exports in, like %s. Supported: .zip, .tar, .tar.gz, .tgz. Optional.\n", fromArchiveArg, fromArg)
+	fmt.Fprintf(w, "%s: Source archives to find imports in, like %s. Optional.\n", toArchiveArg, toArg)
+	fmt.Fprintf(w, "%s: Add the date each unused export's file was last committed. Optional.\n", gitAgeArg)
+	fmt.Fprintf(w, "%s: Add the file and line each unused export is declared at. Optional.\n", positionsArg)
+	fmt.Fprintf(w, "%s: How to print the report. text prints one unused export per line, list does too but always sorted by symbol, csv prints their symbol, kind, file and line. Supported: %s. Defaults to json. Optional.\n", formatArg, strings.Join(outputFormats, ", "))
+	fmt.Fprintf(w, "%s: Experimental. Delete unused vars and consts from the source where it's safe, and list the rest. Optional.\n", fixArg)
+	fmt.Fprintf(w, "%s: Exit with %d if there are any unused exports, instead of 0. Usage errors exit with 1, and other errors with 2. Optional.\n", failOnUnusedArg, unusedExitCode)
+	fmt.Fprintf(w, "%s: Main packages, as directories or patterns, whose files and dependencies' files to find imports in, like %s. Optional.\n", toDepsArg, toArg)
+	fmt.Fprintf(w, "%s: Files with the output of go list -json, like from go list -json -deps ./cmd/app, whose packages' files to find imports in, like %s. Optional.\n", packagesJSONArg, toArg)
+	fmt.Fprintf(w, "%s: List exports that are only referenced by generated files, which still count as used. Optional.\n", generatedOnlyUsageArg)
+	fmt.Fprintf(w, "%s: Print the time spent loading each package to stderr at the end, slowest first. Optional.\n", profilePackagesArg)
+	fmt.Fprintf(w, "%s: How many files to parse at once. Defaults to the number of CPUs. Optional.\n", jobsArg)
+	fmt.Fprintf(w, "%s: Print the packages found in %s and %s, with how many of their files are scanned, and exit without comparing. Optional.\n", listPackagesArg, fromArg, toArg)
+	fmt.Fprintf(w, "%s: YAML or JSON manifest whose strings name exports to credit as used, like plugin registries. Optional.\n", refManifestArg)
+	fmt.Fprintf(w, "%s: Regular expression whose first group captures export names from string literals in %s, like RPC routes, to credit as used. Optional.\n", refRegexArg, toArg)
+	fmt.Fprintf(w, "%s: Dot separated path to the names in %s, where * matches any key or element, like plugins.*.handler. Defaults to every string. Optional.\n", manifestKeyArg, refManifestArg)
+	fmt.Fprintf(w, "%s: List the packages that are only imported blank, for their side effects. Optional.\n", blankImportsArg)
+	fmt.Fprintf(w, "%s: Skip go files larger than this many bytes, with a warning, like giant generated files. Optional.\n", maxFileSizeArg)
+	fmt.Fprintf(w, "%s: Comma separated build tags for every file, in %s and %s. Files are only read if their build constraints are satisfied for this GOOS and GOARCH. Optional.\n", buildTagsArg, fromArg, toArg)
+	fmt.Fprintf(w, "%s: How references from test files in the %s directories count: include counts them, exclude ignores them, and separate ignores them but lists the exports only tests use. Defaults to include. Optional.\n", testRefsArg, toArg)
+	fmt.Fprintf(w, "%s: Also compare where unused exports are declared with the %s, so moved exports count as new. Needs a json baseline written with %s. Optional.\n", baselinePositionsArg, baselineArg, positionsArg)
+	fmt.Fprintf(w, "%s: Print a JSON Schema describing the json report and exit. Optional.\n", jsonSchemaArg)
+	fmt.Fprintf(w, "%s: List the files that reference each export in the json report's Imported, as objects with a symbol and usedBy. Optional.\n", withUsagesArg)
+	fmt.Fprintln(w, "Examples:")
+	fmt.Fprintf(w, "\trefaudit %s /path/to/library/ %s /path/to/app1 /path/to/app2 | tee ~/unused1.json\n", fromArg, toArg)
+	fmt.Fprintf(w, "\trefaudit %s /path/to/library/ %s /path/to/app1 %s /path/to/app1/exclude | tee ~/unused2.json\n", fromArg, toArg, excludeToArg)
+	fmt.Fprintf(w, "\trefaudit %s /path/to/library/ %s /path/to/app1 %s 'no unused exports allowed in public packages'\n", fromArg, toArg, assertArg)
+}
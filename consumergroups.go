@@ -0,0 +1,24 @@
+package main
+
+// orphanExitCode is returned when --require-consumer-group is set and some
+// exports aren't referenced by that group.
+const orphanExitCode = 5
+
+// groupOrphans returns the exports that no file in dirs, the directories of a
+// consumer group, references, sorted.
+func groupOrphans(exports map[string]interface{}, refs map[string]map[string]int, dirs []string) []string {
+	orphans := []string{}
+	for symbol := range exports {
+		used := false
+		for file := range refs[symbol] {
+			if isExcluded(file, dirs) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			orphans = sortedInsert(orphans, symbol)
+		}
+	}
+	return orphans
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+	"golang.org/x/tools/go/packages"
+)
+
+// consumerCoupling counts, for each consumer package, how many distinct exports
+// it references. The most coupled consumers come first.
+func consumerCoupling(pkgs pkgResolver, exports map[string]interface{}, refs map[string]map[string]int) []Coupling {
+	counts := make(map[string]int)
+	for symbol, files := range refs {
+		if _, ok := exports[symbol]; !ok {
+			continue
+		}
+		consumers := make(map[string]interface{})
+		for file := range files {
+			consumers[pkgs.pkgPath(file)] = exists
+		}
+		for consumer := range consumers {
+			counts[consumer]++
+		}
+	}
+
+	coupling := make([]Coupling, 0, len(counts))
+	for consumer, n := range counts {
+		coupling = append(coupling, Coupling{Consumer: consumer, Exports: n})
+	}
+	sort.Slice(coupling, func(i, j int) bool {
+		if coupling[i].Exports != coupling[j].Exports {
+			return coupling[i].Exports > coupling[j].Exports
+		}
+		return coupling[i].Consumer < coupling[j].Consumer
+	})
+	return coupling
+}
+
+// singleConsumerExports lists the exports that only one consumer package
+// references, sorted by export.
+func singleConsumerExports(pkgs pkgResolver, exports map[string]interface{}, refs map[string]map[string]int) []SingleConsumer {
+	single := []SingleConsumer{}
+	for symbol, files := range refs {
+		if _, ok := exports[symbol]; !ok {
+			continue
+		}
+		consumers := make(map[string]interface{})
+		for file := range files {
+			consumers[pkgs.pkgPath(file)] = exists
+		}
+		if len(consumers) != 1 {
+			continue
+		}
+		for consumer := range consumers {
+			single = append(single, SingleConsumer{Export: symbol, Consumer: consumer})
+		}
+	}
+	sort.Slice(single, func(i, j int) bool { return single[i].Export < single[j].Export })
+	return single
+}
+
+// referencedBy maps each referenced export to the consumer packages that
+// reference it, sorted.
+func referencedBy(pkgs pkgResolver, exports map[string]interface{}, refs map[string]map[string]int) map[string][]string {
+	by := make(map[string][]string)
+	for symbol, files := range refs {
+		if _, ok := exports[symbol]; !ok {
+			continue
+		}
+		consumers := []string{}
+		for file := range files {
+			consumers = sortedInsert(consumers, pkgs.pkgPath(file))
+		}
+		by[symbol] = consumers
+	}
+	return by
+}
+
+// pkgResolver maps files to the import path of the package they belong to,
+// loading each package only once. It is safe for concurrent use, and meant to
+// be shared by everything that resolves the same files.
+type pkgResolver struct {
+	fs *token.FileSet
+	// guards cache
+	mu *sync.Mutex
+	// dir:package name -> import path
+	cache map[string]string
+}
+
+func newPkgResolver() pkgResolver {
+	return pkgResolver{token.NewFileSet(), &sync.Mutex{}, make(map[string]string)}
+}
+
+// pkgPath returns the import path of file's package, or its directory if the
+// package can't be loaded.
+func (r pkgResolver) pkgPath(file string) string {
+	dir := filepath.Dir(file)
+	f, err := parser.ParseFile(r.fs, file, nil, parser.PackageClauseOnly)
+	if err != nil {
+		return dir
+	}
+	name := f.Name.Name
+	key := dir + ":" + name
+	r.mu.Lock()
+	pkgPath, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
+		return pkgPath
+	}
+
+	pkgPath = dir
+	cfg := &packages.Config{Mode: packages.NeedName, Tests: false, Dir: dir}
+	pkgs, err := packages.Load(cfg, ".")
+	if err == nil {
+		for _, pkg := range pkgs {
+			switch {
+			case pkg.PkgPath == "":
+			case pkg.Name == name:
+				pkgPath = symbols.NormalizePkgPath(pkg.PkgPath)
+			case pkg.Name+"_test" == name:
+				// external test package
+				pkgPath = symbols.NormalizePkgPath(pkg.PkgPath) + "_test"
+			}
+		}
+	}
+	r.mu.Lock()
+	r.cache[key] = pkgPath
+	r.mu.Unlock()
+	return pkgPath
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// depFiles returns the go files of pkg and every package it depends on,
+// leaving out the standard library. pkg is either a directory, or a package
+// pattern resolved from the current directory.
+func depFiles(ctx context.Context, pkg string) ([]string, error) {
+	dir, pattern := "", pkg
+	if info, err := os.Stat(pkg); err == nil && info.IsDir() {
+		dir, pattern = pkg, "."
+	}
+	cmd := exec.CommandContext(ctx, "go", "list", "-deps", "-json", pattern)
+	cmd.Dir = dir
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list the dependencies of %s: %w: %s", pkg, err, strings.TrimSpace(stderr.String()))
+	}
+
+	files, err := listedFiles(bytes.NewReader(out), "")
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the dependencies of %s: %w", pkg, err)
+	}
+	return files, nil
+}
+
+// packagesJSONFiles returns the go files of the packages in file, which holds
+// the output of go list -json, leaving out the standard library. Relative
+// package directories are relative to file's directory.
+func packagesJSONFiles(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read packages json: %w", err)
+	}
+	defer f.Close()
+	files, err := listedFiles(f, filepath.Dir(file))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse packages json %s: %w", file, err)
+	}
+	return files, nil
+}
+
+// listedFiles reads the packages go list -json writes to r, and returns the go
+// files of the ones outside the standard library. Relative package directories
+// are joined to dir.
+func listedFiles(r io.Reader, dir string) ([]string, error) {
+	files := []string{}
+	dec := json.NewDecoder(r)
+	for {
+		var listed struct {
+			Dir      string
+			Standard bool
+			GoFiles  []string
+			CgoFiles []string
+		}
+		if err := dec.Decode(&listed); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if listed.Standard {
+			continue
+		}
+		if !filepath.IsAbs(listed.Dir) {
+			listed.Dir = filepath.Join(dir, listed.Dir)
+		}
+		for _, name := range append(listed.GoFiles, listed.CgoFiles...) {
+			files = append(files, filepath.Join(listed.Dir, name))
+		}
+	}
+	return files, nil
+}
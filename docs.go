@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// docSelector matches package-qualified exported names, like "dummy.Less".
+var docSelector = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)\.([A-Z][A-Za-z0-9_]*)\b`)
+
+// findDocUsages returns the symbols referenced by Example functions and by code
+// blocks in comments in dirs. Example functions are only understood in external
+// test packages, where they have to qualify the package they document.
+func findDocUsages(ctx context.Context, dirs []string, excluding []string) (map[string]interface{}, error) {
+	usages := make(map[string]interface{})
+	pkgs := newPkgResolver()
+	var mu sync.Mutex
+
+	fs := token.NewFileSet()
+	err := runOnFiles(ctx, dirs, excluding, func(file string) error {
+		f, err := parser.ParseFile(fs, file, nil, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", file, err)
+		}
+
+		self := pkgs.pkgPath(file)
+
+		mu.Lock()
+		defer mu.Unlock()
+		v := symbols.NewRefVisitor(f)
+		if isTestFile(file) {
+			for _, decl := range f.Decls {
+				if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv == nil && strings.HasPrefix(fn.Name.Name, "Example") {
+					ast.Walk(v, fn)
+				}
+			}
+		}
+		for symbol := range v.Refs() {
+			usages[symbol] = exists
+		}
+
+		// comments can refer to their own package by name
+		for _, cg := range f.Comments {
+			for _, code := range commentCode(cg.Text()) {
+				for _, m := range docSelector.FindAllStringSubmatch(code, -1) {
+					if m[1] == f.Name.Name {
+						usages[self+"."+m[2]] = exists
+					} else if imp, ok := v.Imports()[m[1]]; ok {
+						usages[imp+"."+m[2]] = exists
+					}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find doc usages: %w", err)
+	}
+	return usages, nil
+}
+
+// commentCode returns the code blocks in a comment's text: indented lines, as
+// gofmt formats them, and blocks fenced by ```.
+func commentCode(text string) []string {
+	code := []string{}
+	fenced := false
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(strings.TrimSpace(line), "```"):
+			fenced = !fenced
+		case fenced, strings.HasPrefix(line, "\t"), strings.HasPrefix(line, "  "):
+			code = append(code, line)
+		}
+	}
+	return code
+}
+
+// isTestFile reports whether file is a go test file.
+func isTestFile(file string) bool {
+	return strings.HasSuffix(file, "_test.go")
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+const doctorCmd = "doctor"
+
+// doctorFiles make up a trivial module that a healthy environment can load.
+var doctorFiles = map[string]string{
+	"go.mod":    "module example.com/doctor\n\ngo 1.17\n",
+	"doctor.go": "package doctor\n\nfunc Healthy() {}\n",
+	// a different package name that is only fine if build constraints are honored
+	"ignored.go": "//go:build ignore\n// +build ignore\n\npackage ignored\n",
+}
+
+// doctorCheck is one line of the doctor checklist.
+type doctorCheck struct {
+	name string
+	err  error
+}
+
+// doctor checks that the go toolchain can load packages the way an audit
+// needs to, printing a checklist. It returns the exit code to use.
+func doctor(ctx context.Context, stdout io.Writer) int {
+	checks := []doctorCheck{}
+
+	version, err := goEnv(ctx, "GOVERSION")
+	checks = append(checks, doctorCheck{"go toolchain", err})
+	goflags, _ := goEnv(ctx, "GOFLAGS")
+	fmt.Fprintf(stdout, "go version: %s\n", version)
+	fmt.Fprintf(stdout, "GOFLAGS: %s\n", goflags)
+
+	checks = append(checks, doctorLoad(ctx)...)
+
+	code := 0
+	for _, check := range checks {
+		if check.err != nil {
+			fmt.Fprintf(stdout, "[FAIL] %s: %v\n", check.name, check.err)
+			code = 2
+		} else {
+			fmt.Fprintf(stdout, "[PASS] %s\n", check.name)
+		}
+	}
+	return code
+}
+
+// doctorLoad loads the doctor module and checks what the loader found.
+func doctorLoad(ctx context.Context) []doctorCheck {
+	load := doctorCheck{name: "load packages"}
+	module := doctorCheck{name: "module resolution"}
+	constraints := doctorCheck{name: "build constraints"}
+	skipped := fmt.Errorf("skipped, packages could not be loaded")
+
+	dir, err := os.MkdirTemp("", "refaudit-doctor")
+	if err != nil {
+		load.err = err
+		module.err, constraints.err = skipped, skipped
+		return []doctorCheck{load, module, constraints}
+	}
+	defer os.RemoveAll(dir)
+	for name, content := range doctorFiles {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			load.err = err
+			module.err, constraints.err = skipped, skipped
+			return []doctorCheck{load, module, constraints}
+		}
+	}
+
+	cfg := &packages.Config{Context: ctx, Mode: packages.NeedName | packages.NeedFiles | packages.NeedModule, Dir: dir}
+	pkgs, err := packages.Load(cfg, "./...")
+	switch {
+	case err != nil:
+		load.err = err
+	case len(pkgs) != 1:
+		load.err = fmt.Errorf("expected 1 package, found %d", len(pkgs))
+	case len(pkgs[0].Errors) > 0:
+		load.err = pkgs[0].Errors[0]
+	}
+	if load.err != nil {
+		module.err, constraints.err = skipped, skipped
+		return []doctorCheck{load, module, constraints}
+	}
+
+	pkg := pkgs[0]
+	if pkg.PkgPath != "example.com/doctor" || pkg.Module == nil || pkg.Module.Path != "example.com/doctor" {
+		module.err = fmt.Errorf("package resolved as %q instead of %q, exports will not match imports", pkg.PkgPath, "example.com/doctor")
+	}
+	for _, file := range pkg.GoFiles {
+		if filepath.Base(file) == "ignored.go" {
+			constraints.err = fmt.Errorf("loaded %s, which is excluded by its build constraint", file)
+		}
+	}
+	return []doctorCheck{load, module, constraints}
+}
+
+// goEnv returns the value of a go env variable.
+func goEnv(ctx context.Context, name string) (string, error) {
+	out, err := exec.CommandContext(ctx, "go", "env", name).Output()
+	if err != nil {
+		return "", fmt.Errorf("go env %s failed: %w", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/launchdarkly-labs/refaudit/symbols"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,8 +29,10 @@ func TestFileList(t *testing.T) {
 
 func TestExports(t *testing.T) {
 	searchDir := expandPath("./internal/dummy/")
-	exports, err := findExports(context.TODO(), []string{searchDir}, []string{})
+	scan, err := findExports(context.TODO(), []string{searchDir}, []string{}, nil, nil, moduleFilter{}, false, false)
 	require.NoError(t, err)
+	require.Empty(t, scan.diagnostics)
+	exports := scan.exports
 	if _, ok := exports["github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedFunction"]; !ok {
 		assert.FailNow(t, "missing exported function")
 	}
@@ -37,11 +45,32 @@ func TestExports(t *testing.T) {
 	if _, ok := exports["github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedInterface"]; !ok {
 		assert.FailNow(t, "missing exported interface")
 	}
+	if _, ok := exports["github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedConstant"]; !ok {
+		assert.FailNow(t, "missing exported constant")
+	}
+	assert.Equal(t, symbols.Const, scan.kinds["github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedConstant"])
+	assert.Contains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.FirstLevel", "iota constant")
+	assert.Contains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.SecondLevel", "implicitly repeated iota constant")
+	assert.NotContains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.unexportedLevel")
+	assert.Contains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Start", "function")
+	assert.Contains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Server.Start", "method with a value receiver")
+	assert.Contains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Server.Stop", "method with a pointer receiver")
+	assert.NotContains(t, exports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Stop")
+	assert.Equal(t, symbols.Method, scan.kinds["github.com/launchdarkly-labs/refaudit/internal/dummy.Server.Stop"])
+}
+
+func TestExportLoadDiagnostics(t *testing.T) {
+	searchDir := expandPath("./testdata/loaderror/")
+	scan, err := findExports(context.TODO(), []string{searchDir}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	require.Len(t, scan.diagnostics, 1)
+	assert.Contains(t, scan.diagnostics[0], "github.com/launchdarkly-labs/refaudit/testdata/loaderror: ")
+	assert.Contains(t, scan.diagnostics[0], "found packages")
 }
 
 func TestImports(t *testing.T) {
 	searchDir := expandPath("./internal/dummy/")
-	imports, err := findImports(context.TODO(), []string{searchDir}, []string{})
+	imports, err := findImports(context.TODO(), []string{searchDir}, []string{}, nil, moduleFilter{})
 	require.NoError(t, err)
 	if _, ok := imports["fmt.Print"]; !ok {
 		assert.FailNow(t, "missing imported function call")
@@ -50,3 +79,471 @@ func TestImports(t *testing.T) {
 		assert.FailNow(t, "missing imported interface ref")
 	}
 }
+
+func TestConsumerImports(t *testing.T) {
+	dummy := "github.com/launchdarkly-labs/refaudit/internal/dummy."
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/consumer/"), expandPath("./testdata/typeparams/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		symbol string
+		reason string
+	}{
+		{"Less", "function passed to sort.Slice"},
+		{"Handler", "function passed to http.HandleFunc"},
+		{"HeaderLen", "const used in a slice expression"},
+		{"Offset", "const used in an index expression"},
+		{"StatusOK", "const used as a map key"},
+		{"IdxA", "const used as an array element key"},
+		{"IdxB", "const used as an array element key"},
+		{"Step", "call in a labeled loop"},
+		{"DefaultTimeout", "const used as a struct field value"},
+		{"Mode", "type of a typed const"},
+		{"ModeFast", "value of a typed const"},
+		{"Ping", "func stored as a map value"},
+		{"CurrentMode", "switch tag"},
+		{"Value", "type switch expression"},
+		{"Hook", "var on the left of an assignment"},
+		{"Pool", "var whose method is deferred"},
+		{"Config", "field type of an anonymous struct"},
+		{"Elem", "slice element"},
+		{"Key", "map key"},
+		{"Val", "map value"},
+		{"Msg", "channel element"},
+		{"Arg", "func parameter"},
+		{"Result", "func result"},
+		{"Constraint", "type parameter constraint"},
+		{"TypeArg", "type argument"},
+		{"Compute", "call in a return statement"},
+		{"Fallback", "var in a return statement"},
+		{"Allocated", "type passed to new"},
+		{"Buffer", "type passed to make"},
+		{"Appended", "var passed to append"},
+		{"Copied", "var passed to copy"},
+		{"Deleted", "const passed to delete"},
+		{"Options.Timeout", "key of a literal"},
+		{"Options.Retries", "key of a pointer literal"},
+		{"Options.Name", "key of a literal with its type elided"},
+		{"Setup", "if init"},
+		{"Enabled", "if condition"},
+		{"First", "for init"},
+		{"Max", "for condition"},
+		{"Next", "for post"},
+		{"Server.Start", "method called on a variable of the type"},
+	} {
+		t.Run(tc.reason, func(t *testing.T) {
+			assert.Contains(t, imports, dummy+tc.symbol)
+		})
+	}
+	assert.NotContains(t, imports, dummy+"Options.Unset")
+	assert.NotContains(t, imports, dummy+"Server.Stop")
+}
+
+func TestMajorVersionImports(t *testing.T) {
+	scan, err := findExports(context.TODO(), []string{expandPath("./internal/dummy/v2/")}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	exports := scan.exports
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/consumer/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	key := "github.com/launchdarkly-labs/refaudit/internal/dummy/v2.ExportedFunction"
+	require.Contains(t, exports, key)
+	require.Contains(t, imports, key)
+}
+
+func TestReferencedBy(t *testing.T) {
+	scan, err := findExports(context.TODO(), []string{expandPath("./internal/dummy/")}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/coupling/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	dummy := "github.com/launchdarkly-labs/refaudit/internal/dummy."
+	heavy := "github.com/launchdarkly-labs/refaudit/testdata/coupling/heavy"
+	light := "github.com/launchdarkly-labs/refaudit/testdata/coupling/light"
+	assert.Equal(t, map[string][]string{
+		dummy + "ExportedFunction": {heavy, light},
+		dummy + "Less":             {heavy},
+		dummy + "ExportedVariable": {heavy},
+	}, referencedBy(newPkgResolver(), scan.exports, imports))
+}
+
+func TestProfilePackages(t *testing.T) {
+	profile := newLoadProfile()
+	ctx := withLoadProfile(context.TODO(), profile)
+	_, err := findExports(ctx, []string{expandPath("./testdata/coupling/")}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	heavy := "github.com/launchdarkly-labs/refaudit/testdata/coupling/heavy"
+	light := "github.com/launchdarkly-labs/refaudit/testdata/coupling/light"
+	assert.Len(t, profile.times, 2)
+	assert.Contains(t, profile.times, heavy)
+	assert.Contains(t, profile.times, light)
+
+	profile = newLoadProfile()
+	profile.times["fast"] = time.Millisecond
+	profile.times["slow"] = time.Second
+	out := &bytes.Buffer{}
+	require.NoError(t, profile.write(out))
+	assert.Equal(t, "package load times:\n1s\tslow\n1ms\tfast\n", out.String())
+}
+
+func TestConsumerCoupling(t *testing.T) {
+	scan, err := findExports(context.TODO(), []string{expandPath("./internal/dummy/")}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/coupling/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []Coupling{
+		{Consumer: "github.com/launchdarkly-labs/refaudit/testdata/coupling/heavy", Exports: 3},
+		{Consumer: "github.com/launchdarkly-labs/refaudit/testdata/coupling/light", Exports: 1},
+	}, consumerCoupling(newPkgResolver(), scan.exports, imports))
+}
+
+func TestInterfaceUses(t *testing.T) {
+	uses, err := findTypedUses(context.TODO(), []string{expandPath("./testdata/consumer/")}, []string{}, nil, moduleFilter{}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, uses.interfaces, "github.com/launchdarkly-labs/refaudit/internal/dummy.Greeter.Greet")
+	assert.NotContains(t, uses.interfaces, "github.com/launchdarkly-labs/refaudit/internal/dummy.Greeter.String")
+}
+
+func TestInterfaceUsesOfKinds(t *testing.T) {
+	to := []string{expandPath("./testdata/consumer/")}
+	all, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, nil)
+	require.NoError(t, err)
+	typesOnly, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, map[symbols.Kind]interface{}{symbols.Type: exists})
+	require.NoError(t, err)
+	assert.Equal(t, all.interfaces, typesOnly.interfaces)
+	assert.Equal(t, all.methods, typesOnly.methods)
+	funcsOnly, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, map[symbols.Kind]interface{}{symbols.Func: exists})
+	require.NoError(t, err)
+	assert.Empty(t, funcsOnly.interfaces)
+	assert.Empty(t, funcsOnly.methods)
+}
+
+func BenchmarkTypedUses(b *testing.B) {
+	to := []string{expandPath("./testdata/consumer/")}
+	for name, kinds := range map[string]map[symbols.Kind]interface{}{
+		"all":   nil,
+		"types": {symbols.Type: exists},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, kinds); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestGroupByPackage(t *testing.T) {
+	searchDir := expandPath("./internal/dummy/")
+	noExports := "github.com/launchdarkly-labs/refaudit/internal/dummy/noexports"
+	groupedPackages := func(excludeEmpty bool) []string {
+		scan, err := findExports(context.TODO(), []string{searchDir}, []string{}, nil, nil, moduleFilter{}, false, excludeEmpty)
+		require.NoError(t, err)
+		rpt := Report{Exported: []string{}, UnusedExports: []string{}}
+		for k := range scan.exports {
+			rpt.Exported = sortedInsert(rpt.Exported, k)
+		}
+		found := []string{}
+		for _, pkg := range groupByPackage(rpt, scan.packages, excludeEmpty) {
+			found = append(found, pkg.Package)
+		}
+		return found
+	}
+
+	assert.Equal(t, []string{
+		"github.com/launchdarkly-labs/refaudit/internal/dummy",
+		noExports,
+		"github.com/launchdarkly-labs/refaudit/internal/dummy/v2",
+	}, groupedPackages(false))
+	assert.NotContains(t, groupedPackages(true), noExports)
+	assert.Contains(t, groupedPackages(true), "github.com/launchdarkly-labs/refaudit/internal/dummy/v2")
+}
+
+func TestTemplate(t *testing.T) {
+	tmpl, err := parseTemplate(expandPath("./testdata/templates/summary.tmpl"))
+	require.NoError(t, err)
+	rpt := Report{
+		Exported:      []string{"dummy.A", "dummy.B", "dummy.C"},
+		Imported:      []string{"dummy.C"},
+		UnusedExports: []string{"dummy.A", "dummy.B"},
+	}
+	out := &strings.Builder{}
+	require.NoError(t, renderTemplate(out, tmpl, rpt))
+	assert.Equal(t, "2 of 3 unused: dummy.A, dummy.B\n", out.String())
+}
+
+func TestParenthesizedImports(t *testing.T) {
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/parens/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Contains(t, imports, "fmt.Println")
+	assert.Contains(t, imports, "fmt.Sprint")
+}
+
+func TestImportAliases(t *testing.T) {
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/aliases/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	// explicit names win over the path basename
+	assert.Contains(t, imports, "example.com/foo/bar.Call")
+	assert.NotContains(t, imports, "example.com/real/bar.Call")
+	assert.Contains(t, imports, "example.com/notfmt.Println")
+	assert.NotContains(t, imports, "fmt.Println")
+	// blank and dot imports don't shadow a package with the same basename
+	assert.Contains(t, imports, "example.com/real/bar.Real")
+	assert.NotContains(t, imports, "example.com/blank/bar.Real")
+	assert.NotContains(t, imports, "example.com/dot/bar.Real")
+}
+
+func TestDocUsages(t *testing.T) {
+	usages, err := findDocUsages(context.TODO(), []string{expandPath("./testdata/docs/")}, []string{})
+	require.NoError(t, err)
+	assert.Contains(t, usages, "github.com/launchdarkly-labs/refaudit/testdata/docs.Documented")
+	assert.Contains(t, usages, "github.com/launchdarkly-labs/refaudit/testdata/docs.Commented")
+	assert.Contains(t, usages, "github.com/launchdarkly-labs/refaudit/testdata/docs.Fenced")
+	assert.NotContains(t, usages, "github.com/launchdarkly-labs/refaudit/testdata/docs.Undocumented")
+}
+
+func TestOverlappingRoots(t *testing.T) {
+	dir := expandPath("./testdata/")
+	roots := []string{filepath.Join(dir, "aliases"), dir, dir + fsep, expandPath("./testdata/aliases"), filepath.Join(dir, "parens")}
+	found := make(map[string]int)
+	require.NoError(t, runOnFiles(context.TODO(), roots, []string{}, func(file string) error {
+		found[file]++
+		return nil
+	}))
+	require.Contains(t, found, filepath.Join(dir, "aliases", "renamed.go"))
+	for file, n := range found {
+		assert.Equal(t, 1, n, file)
+	}
+	assert.Equal(t, []string{dir}, dedupeRoots(roots))
+}
+
+func TestExcludeFileRegex(t *testing.T) {
+	searchDir := expandPath("./testdata/generated/")
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/generated"
+
+	scan, err := findExports(context.TODO(), []string{searchDir}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	assert.Contains(t, scan.exports, pkg+".Generated")
+	assert.Contains(t, scan.exports, pkg+"/mocks.MockAPI")
+
+	scan, err = findExports(context.TODO(), []string{searchDir}, []string{}, regexp.MustCompile(`_generated\.go$|/mock_[^/]*\.go$`), nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{pkg + ".API": exists}, scan.exports)
+}
+
+func TestTestVariantExports(t *testing.T) {
+	searchDir := expandPath("./testdata/testvariants/")
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/testvariants"
+
+	scan, err := findExports(context.TODO(), []string{searchDir}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{pkg + ".Lib": exists}, scan.exports)
+
+	scan, err = findExports(context.TODO(), []string{searchDir}, []string{}, nil, nil, moduleFilter{}, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		pkg + ".Lib":                 exists,
+		pkg + ".InternalHelper":      exists,
+		pkg + "_test.ExternalHelper": exists,
+	}, scan.exports)
+	assert.Empty(t, scan.diagnostics)
+}
+
+func TestBuildTagImports(t *testing.T) {
+	searchDir := expandPath("./testdata/tagged/")
+	key := "github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedFunction"
+
+	imports, err := findImports(context.TODO(), []string{searchDir}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.NotContains(t, imports, key)
+
+	imports, err = findImports(context.TODO(), []string{searchDir}, []string{}, buildTags{searchDir: {"integration"}}, moduleFilter{})
+	require.NoError(t, err)
+	assert.Contains(t, imports, key)
+}
+
+func TestNestedExclusions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"top.go":               "package top\n",
+		"internal/a.go":        "package internal\n",
+		"internal/b.go":        "package internal\n",
+		"internal/deep/c.go":   "package deep\n",
+		"internal/deep/x/d.go": "package x\n",
+		"public/e.go":          "package public\n",
+	})
+	walk := func(excluding ...string) []string {
+		found := []string{}
+		require.NoError(t, runOnFiles(context.TODO(), []string{dir}, excluding, func(file string) error {
+			rel, err := filepath.Rel(dir, file)
+			require.NoError(t, err)
+			found = append(found, filepath.ToSlash(rel))
+			return nil
+		}))
+		return found
+	}
+
+	assert.ElementsMatch(t, []string{"top.go", "internal/a.go", "internal/b.go", "public/e.go"}, walk(filepath.Join(dir, "internal", "deep")))
+	assert.ElementsMatch(t, []string{"top.go", "public/e.go"}, walk(filepath.Join(dir, "internal")+string(filepath.Separator)), "trailing separator")
+	assert.ElementsMatch(t, []string{"top.go", "public/e.go"}, walk(dir+"/public/../internal/."), "unclean path")
+	assert.ElementsMatch(t, []string{"top.go", "internal/b.go", "internal/deep/c.go", "internal/deep/x/d.go", "public/e.go"}, walk(filepath.Join(dir, "internal", "a.go")), "a file leaves its siblings alone")
+	assert.Len(t, walk(filepath.Join(dir, "intern")), 6, "not a path prefix")
+}
+
+func TestMaxDepth(t *testing.T) {
+	dir := expandPath("./testdata/generated")
+	walk := func(depth int) []string {
+		found := []string{}
+		require.NoError(t, runOnFiles(withMaxDepth(context.TODO(), depth), []string{dir}, []string{}, func(file string) error {
+			found = append(found, file)
+			return nil
+		}))
+		return found
+	}
+
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "api.go"), filepath.Join(dir, "zz_generated.go")}, walk(0))
+	assert.Contains(t, walk(1), filepath.Join(dir, "mocks", "mock_api.go"))
+}
+
+func TestExcludeGlobs(t *testing.T) {
+	dir := expandPath("./testdata/generated")
+	walk := func(excluding []string, patterns ...string) []string {
+		found := []string{}
+		require.NoError(t, runOnFiles(withExcludeGlobs(context.TODO(), patterns), []string{dir}, excluding, func(file string) error {
+			found = append(found, file)
+			return nil
+		}))
+		return found
+	}
+
+	all := []string{filepath.Join(dir, "api.go"), filepath.Join(dir, "zz_generated.go"), filepath.Join(dir, "mocks", "mock_api.go")}
+	assert.ElementsMatch(t, all, walk(nil))
+	assert.ElementsMatch(t, all[:2], walk(nil, "mock_*.go"))
+	assert.ElementsMatch(t, all[:1], walk(nil, "mock_*.go", "zz_*"))
+	assert.ElementsMatch(t, all[:1], walk([]string{filepath.Join(dir, "mocks")}, "*generated.go"), "composes with directory exclusions")
+	assert.ElementsMatch(t, all, walk(nil, "mocks"), "only file names are matched")
+}
+
+func TestDeferredMethodUses(t *testing.T) {
+	to := []string{expandPath("./testdata/consumer/")}
+	uses, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, uses.methods, "github.com/launchdarkly-labs/refaudit/internal/dummy.ConnPool.Release", "deferred method")
+}
+
+func TestNestedFieldUses(t *testing.T) {
+	dummy := "github.com/launchdarkly-labs/refaudit/internal/dummy."
+	uses, err := findTypedUses(context.TODO(), []string{expandPath("./testdata/consumer/")}, []string{}, nil, moduleFilter{}, nil)
+	require.NoError(t, err)
+	assert.Contains(t, uses.methods, dummy+"Outer.Inner", "middle of a chain")
+	assert.Contains(t, uses.methods, dummy+"Inner.Depth", "end of a chain")
+	assert.Contains(t, uses.methods, dummy+"Outer.Label", "promoted through an embedded field")
+	assert.NotContains(t, uses.methods, dummy+"Inner.Unread")
+}
+
+func TestTypedRefs(t *testing.T) {
+	dummy := "github.com/launchdarkly-labs/refaudit/internal/dummy."
+	to := []string{expandPath("./testdata/consumer/")}
+	uses, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, nil)
+	require.NoError(t, err)
+	counted := expandPath("./testdata/consumer/counted.go")
+	assert.Contains(t, uses.files, counted)
+	assert.Equal(t, map[string]int{counted: 2, expandPath("./testdata/consumer/return.go"): 1}, uses.refs[dummy+"Counted"])
+	assert.Contains(t, uses.refs, dummy+"Options.Timeout", "key in a literal")
+	assert.Contains(t, uses.refs, dummy+"IdxA", "key of an array element")
+	assert.NotContains(t, uses.refs, dummy+"Options.Unset")
+
+	typesOnly, err := findTypedUses(context.TODO(), to, []string{}, nil, moduleFilter{}, map[symbols.Kind]interface{}{symbols.Type: exists})
+	require.NoError(t, err)
+	assert.Empty(t, typesOnly.files, "references aren't resolved without uses")
+}
+
+func TestGeneratedOnlyUsage(t *testing.T) {
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/consumer/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	exports := map[string]interface{}{
+		"github.com/launchdarkly-labs/refaudit/internal/dummy.Inject": exists,
+		"github.com/launchdarkly-labs/refaudit/internal/dummy.Hook":   exists,
+	}
+	only, err := generatedOnlyUsage(exports, imports)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github.com/launchdarkly-labs/refaudit/internal/dummy.Inject"}, only)
+}
+
+func TestJobs(t *testing.T) {
+	dirs := []string{expandPath("./internal/"), expandPath("./testdata/")}
+	scan := func(jobs int) (exportScan, map[string]map[string]int) {
+		ctx := withJobs(context.TODO(), jobs)
+		exports, err := findExports(ctx, dirs, []string{}, nil, nil, newModuleFilter(nil), false, false)
+		require.NoError(t, err)
+		refs, err := findImports(ctx, dirs, []string{}, nil, newModuleFilter(nil))
+		require.NoError(t, err)
+		return exports, refs
+	}
+
+	serialExports, serialRefs := scan(1)
+	require.NotEmpty(t, serialExports.exports)
+	require.NotEmpty(t, serialRefs)
+	for _, jobs := range []int{2, 8} {
+		exports, refs := scan(jobs)
+		assert.Equal(t, serialExports.exports, exports.exports, "%d jobs", jobs)
+		assert.Equal(t, serialExports.kinds, exports.kinds, "%d jobs", jobs)
+		assert.Equal(t, serialRefs, refs, "%d jobs", jobs)
+	}
+}
+
+func TestReferenceCounts(t *testing.T) {
+	counted := "github.com/launchdarkly-labs/refaudit/internal/dummy.Counted"
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/consumer/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{
+		expandPath("./testdata/consumer/counted.go"): 2,
+		expandPath("./testdata/consumer/return.go"):  1,
+	}, imports[counted])
+
+	counts := referenceCounts(map[string]interface{}{counted: exists, "example.com/lib.Unused": exists}, imports)
+	assert.Equal(t, map[string]int{counted: 3, "example.com/lib.Unused": 0}, counts)
+}
+
+func TestDotImports(t *testing.T) {
+	dummy := "github.com/launchdarkly-labs/refaudit/internal/dummy."
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/dotimport/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Contains(t, imports, dummy+"Dotted", "bare name from a dot import")
+	assert.Contains(t, imports, "fmt.Println")
+	assert.NotContains(t, imports, dummy+"Local", "declared in the file")
+	assert.NotContains(t, imports, dummy+"Println", "selected from another package")
+}
+
+func TestBlankImports(t *testing.T) {
+	noexports := "github.com/launchdarkly-labs/refaudit/internal/dummy/noexports"
+	to := []string{expandPath("./testdata/consumer/")}
+	imports, err := findImports(context.TODO(), to, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	for symbol := range imports {
+		assert.False(t, strings.HasPrefix(symbol, noexports+"."), symbol)
+	}
+
+	blank, err := findBlankImports(context.TODO(), to, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{noexports}, blank)
+}
+
+func TestMaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"small.go": "package blob\n",
+		"blob.go":  "package blob\n\nvar Blob = `" + strings.Repeat("x", 4096) + "`\n",
+	})
+	warnings := &bytes.Buffer{}
+	ctx := withMaxFileSize(context.TODO(), 1024, warnings)
+
+	found := []string{}
+	for i := 0; i < 2; i++ {
+		require.NoError(t, runOnFiles(ctx, []string{dir}, []string{}, func(file string) error {
+			found = append(found, file)
+			return nil
+		}))
+	}
+	blob := filepath.Join(dir, "blob.go")
+	assert.Equal(t, []string{filepath.Join(dir, "small.go"), filepath.Join(dir, "small.go")}, found)
+	assert.Equal(t, 1, strings.Count(warnings.String(), blob), "warned once: %s", warnings)
+}
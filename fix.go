@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// FixResult lists what --fix did with the unused vars and consts.
+type FixResult struct {
+	// Removed lists the unused exports that were deleted from the source.
+	Removed []string
+	// Manual lists the unused exports that weren't safe to delete, like consts
+	// in an iota block, vars initialized by a call, or names their own package
+	// still uses.
+	Manual []string
+}
+
+// fixUnused deletes the declarations of the unused vars and consts from their
+// files, leaving the rest of their group or spec alone. Only names that can be
+// removed without changing the meaning of the others, and that their own
+// package and its tests don't use, are deleted.
+func fixUnused(ctx context.Context, unused []string, kinds map[string]symbols.Kind, positions map[string]token.Position, tags buildTags) (*FixResult, error) {
+	// file -> line:column -> symbol
+	targets := make(map[string]map[string]string)
+	for _, symbol := range unused {
+		pos, ok := positions[symbol]
+		if !ok || (kinds[symbol] != symbols.Var && kinds[symbol] != symbols.Const) {
+			continue
+		}
+		if targets[pos.Filename] == nil {
+			targets[pos.Filename] = make(map[string]string)
+		}
+		targets[pos.Filename][fmt.Sprintf("%d:%d", pos.Line, pos.Column)] = symbol
+	}
+
+	files := make([]string, 0, len(targets))
+	for file := range targets {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	result := &FixResult{Removed: []string{}, Manual: []string{}}
+	used, unresolved, err := packageUses(ctx, files, tags)
+	if err != nil {
+		return nil, err
+	}
+	for file, byPos := range targets {
+		_, broken := unresolved[file]
+		for pos, symbol := range byPos {
+			if _, ok := used[file+":"+pos]; ok || broken {
+				result.Manual = append(result.Manual, symbol)
+				delete(byPos, pos)
+			}
+		}
+	}
+	for _, file := range files {
+		if err := fixFile(file, targets[file], result); err != nil {
+			return nil, fmt.Errorf("could not fix %s: %w", file, err)
+		}
+	}
+	sort.Strings(result.Removed)
+	sort.Strings(result.Manual)
+	return result, nil
+}
+
+// packageUses type-checks the packages that files are in, with their tests, and
+// returns the positions of the package-level names they use, as
+// "file:line:column". Files of packages that didn't type-check cleanly are
+// returned as unresolved, since their uses may be missing.
+func packageUses(ctx context.Context, files []string, tags buildTags) (map[string]interface{}, map[string]interface{}, error) {
+	used := make(map[string]interface{})
+	unresolved := make(map[string]interface{})
+	dirs := []string{}
+	for _, file := range files {
+		dirs = sortedInsert(dirs, filepath.Dir(file))
+	}
+	loaded := []string{}
+	for _, dir := range dirs {
+		// loading a directory loads the ones inside it too
+		if isExcluded(dir, loaded) {
+			continue
+		}
+		loaded = append(loaded, dir)
+		tl, err := loadTyped(ctx, dir, nil, tags, map[symbols.Kind]interface{}{symbols.Var: exists, symbols.Const: exists})
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not check what fixed packages use: %w", err)
+		}
+		for _, root := range tl.roots {
+			for d := range tl.diagnostics {
+				if strings.HasPrefix(d, root.pkg.PkgPath+": ") {
+					for _, file := range root.pkg.GoFiles {
+						unresolved[file] = exists
+					}
+					break
+				}
+			}
+			for _, obj := range root.info.Uses {
+				if obj.Pkg() == nil || obj.Parent() != obj.Pkg().Scope() {
+					continue
+				}
+				pos := tl.fs.Position(obj.Pos())
+				used[fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)] = exists
+			}
+		}
+	}
+	return used, unresolved, nil
+}
+
+// fixFile deletes the targeted names from file's package-level var and const
+// declarations, and rewrites it if any were.
+func fixFile(file string, targets map[string]string, result *FixResult) error {
+	fs := token.NewFileSet()
+	f, err := parser.ParseFile(fs, file, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	target := func(name *ast.Ident) (string, bool) {
+		pos := fs.Position(name.Pos())
+		symbol, ok := targets[fmt.Sprintf("%d:%d", pos.Line, pos.Column)]
+		return symbol, ok
+	}
+
+	// the ranges of deleted nodes, whose comments go with them
+	deleted := [][2]token.Pos{}
+	decls := []ast.Decl{}
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || (gd.Tok != token.VAR && gd.Tok != token.CONST) {
+			decls = append(decls, decl)
+			continue
+		}
+		// removing anything from an iota block would renumber the rest
+		repeats := gd.Tok == token.CONST && repeatsValues(gd)
+		specs := []ast.Spec{}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			names, values := []*ast.Ident{}, []ast.Expr{}
+			for i, name := range vs.Names {
+				symbol, ok := target(name)
+				if !ok {
+					names = append(names, name)
+					if len(vs.Values) == len(vs.Names) {
+						values = append(values, vs.Values[i])
+					}
+					continue
+				}
+				safe := !repeats && (len(vs.Values) == 0 ||
+					len(vs.Values) == len(vs.Names) && !hasSideEffects(vs.Values[i]))
+				if !safe {
+					result.Manual = append(result.Manual, symbol)
+					names = append(names, name)
+					if len(vs.Values) == len(vs.Names) {
+						values = append(values, vs.Values[i])
+					}
+					continue
+				}
+				result.Removed = append(result.Removed, symbol)
+			}
+			if len(names) == len(vs.Names) {
+				specs = append(specs, spec)
+				continue
+			}
+			if len(names) == 0 {
+				deleted = append(deleted, nodeRange(vs, vs.Doc, vs.Comment))
+				continue
+			}
+			vs.Names = names
+			if len(vs.Values) > 0 {
+				vs.Values = values
+			}
+			specs = append(specs, vs)
+		}
+		if len(specs) == 0 {
+			deleted = append(deleted, nodeRange(gd, gd.Doc, nil))
+			continue
+		}
+		gd.Specs = specs
+		decls = append(decls, gd)
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	f.Decls = decls
+	comments := []*ast.CommentGroup{}
+	for _, c := range f.Comments {
+		if !inRange(c, deleted) {
+			comments = append(comments, c)
+		}
+	}
+	f.Comments = comments
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fs, f); err != nil {
+		return err
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, buf.Bytes(), info.Mode())
+}
+
+// repeatsValues reports whether a const block has specs that implicitly repeat
+// the previous values, like an iota block.
+func repeatsValues(gd *ast.GenDecl) bool {
+	for _, spec := range gd.Specs {
+		if vs, ok := spec.(*ast.ValueSpec); ok && len(vs.Values) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSideEffects reports whether evaluating x might do more than compute a
+// value, which is assumed of any call or channel receive outside of a function
+// literal.
+func hasSideEffects(x ast.Expr) bool {
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch e := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.CallExpr:
+			found = true
+		case *ast.UnaryExpr:
+			if e.Op == token.ARROW {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// nodeRange returns the range n covers, including its doc and line comments.
+func nodeRange(n ast.Node, doc *ast.CommentGroup, comment *ast.CommentGroup) [2]token.Pos {
+	r := [2]token.Pos{n.Pos(), n.End()}
+	if doc != nil {
+		r[0] = doc.Pos()
+	}
+	if comment != nil {
+		r[1] = comment.End()
+	}
+	return r
+}
+
+// inRange reports whether c is inside one of the ranges.
+func inRange(c *ast.CommentGroup, ranges [][2]token.Pos) bool {
+	for _, r := range ranges {
+		if c.Pos() >= r[0] && c.End() <= r[1] {
+			return true
+		}
+	}
+	return false
+}
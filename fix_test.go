@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFix(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/lib\n\ngo 1.17\n",
+		"lib.go": `package lib
+
+// Group is a grouped var block.
+var (
+	// Unused is deleted.
+	Unused = 1
+	Used   = 2
+	Called = compute()
+)
+
+var KeepA, DropB = 1, 2
+
+// Gone is deleted along with its doc.
+var Gone = "gone"
+
+const (
+	A = iota
+	B
+)
+
+const Limit, Max = 1, 2
+
+var (
+	Timeout = 5
+	Probe   = 3
+	Other   = 1
+)
+
+func compute() int { return 0 }
+
+func Wait() int { return Timeout }
+`,
+		"lib_test.go": `package lib
+
+var _ = Probe
+`,
+		"use/use.go": `package use
+
+import "example.com/lib"
+
+var _ = lib.Used + lib.KeepA + lib.Max + lib.Wait()
+`,
+	})
+	untouched, err := os.ReadFile(filepath.Join(dir, "use", "use.go"))
+	require.NoError(t, err)
+
+	code, out := runArgs(t, fromArg, dir, toArg, filepath.Join(dir, "use"), fixArg)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, &FixResult{
+		Removed: []string{"example.com/lib.DropB", "example.com/lib.Gone", "example.com/lib.Limit", "example.com/lib.Other", "example.com/lib.Unused"},
+		Manual:  []string{"example.com/lib.A", "example.com/lib.B", "example.com/lib.Called", "example.com/lib.Probe", "example.com/lib.Timeout"},
+	}, rpt.Fix)
+
+	fixed, err := os.ReadFile(filepath.Join(dir, "lib.go"))
+	require.NoError(t, err)
+	assert.Equal(t, `package lib
+
+// Group is a grouped var block.
+var (
+	Used   = 2
+	Called = compute()
+)
+
+var KeepA = 1
+
+const (
+	A = iota
+	B
+)
+
+const Max = 2
+
+var (
+	Timeout = 5
+	Probe   = 3
+)
+
+func compute() int { return 0 }
+
+func Wait() int { return Timeout }
+`, string(fixed))
+	after, err := os.ReadFile(filepath.Join(dir, "use", "use.go"))
+	require.NoError(t, err)
+	assert.Equal(t, string(untouched), string(after))
+}
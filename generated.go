@@ -0,0 +1,67 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"regexp"
+	"sort"
+)
+
+// generatedComment is the comment that marks a go file as generated, see
+// https://golang.org/s/generatedcode.
+var generatedComment = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// isGenerated reports whether file has a generated code comment before its
+// package clause.
+func isGenerated(fs *token.FileSet, file string) (bool, error) {
+	f, err := parser.ParseFile(fs, file, nil, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if generatedComment.MatchString(c.Text) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// generatedOnlyUsage returns the exports whose every reference is from a
+// generated file, sorted.
+func generatedOnlyUsage(exports map[string]interface{}, refs map[string]map[string]int) ([]string, error) {
+	fs := token.NewFileSet()
+	// file -> whether it's generated
+	generated := make(map[string]bool)
+	only := []string{}
+	for symbol := range exports {
+		files, ok := refs[symbol]
+		if !ok {
+			continue
+		}
+		all := true
+		for file := range files {
+			gen, ok := generated[file]
+			if !ok {
+				var err error
+				if gen, err = isGenerated(fs, file); err != nil {
+					return nil, err
+				}
+				generated[file] = gen
+			}
+			if !gen {
+				all = false
+				break
+			}
+		}
+		if all {
+			only = append(only, symbol)
+		}
+	}
+	sort.Strings(only)
+	return only, nil
+}
@@ -0,0 +1,26 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeParamConstraintImports(t *testing.T) {
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/generics/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Contains(t, imports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Ordered", "constraint of a generic type")
+}
+
+func TestStdlibGenericImports(t *testing.T) {
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/generics/")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Contains(t, imports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Default", "argument to a generic function")
+	assert.Contains(t, imports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Celsius", "type argument to a generic function")
+	assert.Contains(t, imports, "slices.Max")
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"go/token"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// lineRange is an inclusive range of line numbers.
+type lineRange struct {
+	start, end int
+}
+
+// git runs git in dir and returns its stdout.
+func git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// addedLines returns the lines of each go file under dir that were added since
+// ref, keyed by absolute path. Files git doesn't track count as entirely added.
+func addedLines(ctx context.Context, dir string, ref string) (map[string][]lineRange, error) {
+	added := make(map[string][]lineRange)
+
+	diff, err := git(ctx, dir, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--relative", ref, "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	file := ""
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = ""
+			if name := strings.TrimPrefix(line, "+++ "); strings.HasPrefix(name, "b/") {
+				file = filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, "b/")))
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			// @@ -old[,n] +new[,n] @@
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			start, count, err := parseHunkRange(strings.TrimPrefix(fields[2], "+"))
+			if err != nil {
+				return nil, fmt.Errorf("could not parse diff hunk %q: %w", line, err)
+			}
+			if count > 0 {
+				added[file] = append(added[file], lineRange{start, start + count - 1})
+			}
+		}
+	}
+
+	untracked, err := git(ctx, dir, "ls-files", "--others", "--exclude-standard", "--", ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(untracked), "\n") {
+		if name != "" {
+			added[filepath.Join(dir, filepath.FromSlash(name))] = []lineRange{{1, math.MaxInt32}}
+		}
+	}
+	return added, nil
+}
+
+// parseHunkRange parses the "start,count" half of a diff hunk header.
+func parseHunkRange(s string) (int, int, error) {
+	parts := strings.SplitN(s, ",", 2)
+	start, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	count := 1
+	if len(parts) == 2 {
+		if count, err = strconv.Atoi(parts[1]); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, count, nil
+}
+
+// filterExportsSince drops every export from scan that wasn't declared on a
+// line added to the from directories since ref.
+func filterExportsSince(ctx context.Context, from []string, ref string, scan exportScan) error {
+	added := make(map[string][]lineRange)
+	for _, dir := range from {
+		lines, err := addedLines(ctx, dir, ref)
+		if err != nil {
+			return fmt.Errorf("failed to diff against %s: %w", ref, err)
+		}
+		for file, ranges := range lines {
+			added[file] = append(added[file], ranges...)
+		}
+	}
+
+	for symbol := range scan.exports {
+		pos, ok := scan.positions[symbol]
+		if !ok || !inRanges(added[pos.Filename], pos.Line) {
+			delete(scan.exports, symbol)
+		}
+	}
+	return nil
+}
+
+func inRanges(ranges []lineRange, line int) bool {
+	for _, r := range ranges {
+		if line >= r.start && line <= r.end {
+			return true
+		}
+	}
+	return false
+}
+
+// lastModified returns the date each of the symbols' files was last committed,
+// as YYYY-MM-DD, running git once per file. Symbols without a position, or in
+// files git doesn't track, are left out.
+func lastModified(ctx context.Context, syms []string, positions map[string]token.Position) (map[string]string, error) {
+	dates := make(map[string]string)
+	// file -> date
+	files := make(map[string]string)
+	for _, symbol := range syms {
+		file := positions[symbol].Filename
+		if file == "" {
+			continue
+		}
+		date, ok := files[file]
+		if !ok {
+			out, err := git(ctx, filepath.Dir(file), "log", "-1", "--format=%cs", "--", filepath.Base(file))
+			if err != nil {
+				return nil, fmt.Errorf("could not find when %s was last modified: %w", file, err)
+			}
+			date = strings.TrimSpace(string(out))
+			files[file] = date
+		}
+		if date != "" {
+			dates[symbol] = date
+		}
+	}
+	return dates, nil
+}
+
+// SurfaceDiff compares the exports with the ones at a git tag.
+type SurfaceDiff struct {
+	Tag string
+	// Added lists exports that didn't exist at the tag.
+	Added []string
+	// Removed lists exports that existed at the tag, but don't anymore.
+	Removed []string
+	// NewlyUnused lists unused exports that were used, or didn't exist, at the tag.
+	NewlyUnused []string
+}
+
+// snapshots checks out the git repositories of directories at a ref, read-only,
+// into temporary directories.
+type snapshots struct {
+	ref string
+	// repository root -> checkout
+	roots map[string]string
+	// input directory -> the same directory in its checkout
+	dirs map[string]string
+}
+
+func newSnapshots(ref string) snapshots {
+	return snapshots{ref, make(map[string]string), make(map[string]string)}
+}
+
+// add checks out dir's repository, if it isn't already, and returns where dir
+// is in the checkout.
+func (s snapshots) add(ctx context.Context, dir string) (string, error) {
+	root, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	prefix, err := git(ctx, dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(root))
+	checkout, ok := s.roots[key]
+	if !ok {
+		if checkout, err = os.MkdirTemp("", "refaudit-snapshot"); err != nil {
+			return "", err
+		}
+		s.roots[key] = checkout
+		archive, err := git(ctx, key, "archive", "--format=tar", s.ref)
+		if err != nil {
+			return "", err
+		}
+		if err := untar(bytes.NewReader(archive), checkout); err != nil {
+			return "", fmt.Errorf("could not extract %s at %s: %w", key, s.ref, err)
+		}
+	}
+	mapped := filepath.Join(checkout, filepath.FromSlash(strings.TrimSpace(string(prefix))))
+	s.dirs[dir] = mapped
+	return mapped, nil
+}
+
+// mapped returns where path is in a checkout, or path itself if it isn't in a
+// checked out directory.
+func (s snapshots) mapped(path string) string {
+	for dir, mapped := range s.dirs {
+		if path == dir {
+			return mapped
+		}
+		if isExcluded(path, []string{dir}) {
+			return filepath.Join(mapped, strings.TrimPrefix(path, strings.TrimSuffix(dir, fsep)+fsep))
+		}
+	}
+	return path
+}
+
+// cleanup deletes the checkouts.
+func (s snapshots) cleanup() {
+	for _, checkout := range s.roots {
+		os.RemoveAll(checkout)
+	}
+}
+
+// untar extracts the directories and regular files in a tar archive into dir.
+func untar(archive io.Reader, dir string) error {
+	tr := tar.NewReader(archive)
+	remaining := maxExtractedSize
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, filepath.FromSlash(hdr.Name))
+		if !within(dir, name) {
+			return fmt.Errorf("%s is outside of the archive", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			n, err := extractFile(name, tr, remaining)
+			if err != nil {
+				return err
+			}
+			remaining -= n
+		}
+	}
+}
+
+// surfaceSince audits the from and to directories as they were at tag, and
+// compares the exports and unused exports with the current ones. The to
+// directories are only checked out if they are in the same repository as one of
+// the from directories. Only direct references count at the tag.
+func surfaceSince(ctx context.Context, tag string, in inputs, rpt Report) (*SurfaceDiff, error) {
+	snaps := newSnapshots(tag)
+	defer snaps.cleanup()
+
+	oldFrom := []string{}
+	for _, dir := range in.from {
+		mapped, err := snaps.add(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("could not check out %s at %s: %w", dir, tag, err)
+		}
+		oldFrom = append(oldFrom, mapped)
+	}
+	oldTo := []string{}
+	for _, dir := range in.to {
+		root, err := git(ctx, dir, "rev-parse", "--show-toplevel")
+		if _, ok := snaps.roots[strings.TrimSpace(string(root))]; err == nil && ok {
+			mapped, err := snaps.add(ctx, dir)
+			if err != nil {
+				return nil, fmt.Errorf("could not check out %s at %s: %w", dir, tag, err)
+			}
+			dir = mapped
+		}
+		oldTo = append(oldTo, dir)
+	}
+	mapAll := func(paths []string) []string {
+		mapped := []string{}
+		for _, p := range paths {
+			mapped = append(mapped, snaps.mapped(p))
+		}
+		return mapped
+	}
+
+	scan, err := findExports(ctx, oldFrom, mapAll(in.excludeFrom), in.excludeFile, in.tags.mapped(snaps.mapped), in.excludeModules, in.includeTests, false)
+	if err != nil {
+		return nil, err
+	}
+	if !in.fields {
+		scan.drop(symbols.Field)
+	}
+	if !in.interfaceMethods {
+		scan.drop(symbols.InterfaceMethod)
+	}
+	refs, err := findImports(ctx, oldTo, mapAll(in.excludeTo), in.tags.mapped(snaps.mapped), in.excludeModules)
+	if err != nil {
+		return nil, err
+	}
+	oldUnused := make(map[string]interface{})
+	for symbol := range scan.exports {
+		if _, ok := refs[symbol]; !ok {
+			oldUnused[symbol] = exists
+		}
+	}
+
+	added, removed := diffSymbols(scan.exports, rpt.Exported)
+	newlyUnused, _ := diffSymbols(oldUnused, rpt.UnusedExports)
+	return &SurfaceDiff{Tag: tag, Added: added, Removed: removed, NewlyUnused: newlyUnused}, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newGitRepo creates a go module in a temporary git repository, with files
+// committed as its first commit.
+func newGitRepo(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"go.mod": "module example.com/lib\n\ngo 1.17\n"})
+	writeFiles(t, dir, files)
+	runGit(t, dir, "init", "--quiet")
+	runGit(t, dir, "add", "--all")
+	runGit(t, dir, "commit", "--quiet", "--message", "initial")
+	return dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o600))
+	}
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	args = append([]string{"-C", dir, "-c", "user.name=refaudit", "-c", "user.email=refaudit@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestExportsSince(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		"lib.go": "package lib\n\nfunc Old() {}\n",
+	})
+	runGit(t, dir, "tag", "v1.0.0")
+	writeFiles(t, dir, map[string]string{
+		"lib.go":   "package lib\n\nfunc Old() {}\n\nfunc New() {}\n",
+		"extra.go": "package lib\n\nvar Extra = 1\n",
+	})
+
+	scan, err := findExports(context.TODO(), []string{dir}, []string{}, nil, nil, moduleFilter{}, false, false)
+	require.NoError(t, err)
+	require.Len(t, scan.exports, 3)
+	require.NoError(t, filterExportsSince(context.TODO(), []string{dir}, "v1.0.0", scan))
+	assert.Equal(t, map[string]interface{}{
+		"example.com/lib.New":   exists,
+		"example.com/lib.Extra": exists,
+	}, scan.exports)
+}
+
+func TestSinceTag(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		"lib.go":     "package lib\n\nfunc Kept() {}\n\nfunc Gone() {}\n\nfunc Dead() {}\n",
+		"use/use.go": "package use\n\nimport \"example.com/lib\"\n\nfunc use() {\n\tlib.Kept()\n\tlib.Gone()\n}\n",
+	})
+	runGit(t, dir, "tag", "v1.0.0")
+	writeFiles(t, dir, map[string]string{
+		"lib.go":     "package lib\n\nfunc Kept() {}\n\nfunc Dead() {}\n\nfunc New() {}\n",
+		"use/use.go": "package use\n\nimport \"example.com/lib\"\n\nfunc use() {\n\tlib.New()\n}\n",
+	})
+	runGit(t, dir, "commit", "--quiet", "--all", "--message", "next")
+
+	use := filepath.Join(dir, "use")
+	code, out := runArgs(t, fromArg, dir, excludeFromArg, use, toArg, use, sinceTagArg, "v1.0.0")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, &SurfaceDiff{
+		Tag:         "v1.0.0",
+		Added:       []string{"example.com/lib.New"},
+		Removed:     []string{"example.com/lib.Gone"},
+		NewlyUnused: []string{"example.com/lib.Kept"},
+	}, rpt.SinceTag)
+}
+
+func TestGitAge(t *testing.T) {
+	dir := newGitRepo(t, map[string]string{
+		"lib.go": "package lib\n\nfunc Dead() {}\n",
+	})
+	writeFiles(t, dir, map[string]string{"new.go": "package lib\n\nfunc Uncommitted() {}\n"})
+
+	code, out := runArgs(t, fromArg, dir, toArg, dir, gitAgeArg)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	require.Len(t, rpt.UnusedExports, 2)
+	require.Len(t, rpt.LastModified, 1)
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2}$`, rpt.LastModified["example.com/lib.Dead"])
+}
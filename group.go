@@ -0,0 +1,77 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// groupByPackage splits the report's exports up by the package that declares
+// them. Packages without exports are included unless excludeEmpty is set.
+func groupByPackage(rpt Report, pkgPaths map[string]interface{}, excludeEmpty bool) []PackageReport {
+	groups := make(map[string]*PackageReport)
+	for pkgPath := range pkgPaths {
+		groups[pkgPath] = &PackageReport{Package: pkgPath, Exported: []string{}, UnusedExports: []string{}}
+	}
+	for _, symbol := range rpt.Exported {
+		if g, ok := groups[declaringPackage(symbol, pkgPaths)]; ok {
+			g.Exported = append(g.Exported, symbol)
+		}
+	}
+	for _, symbol := range rpt.UnusedExports {
+		if g, ok := groups[declaringPackage(symbol, pkgPaths)]; ok {
+			g.UnusedExports = append(g.UnusedExports, symbol)
+		}
+	}
+
+	pkgs := []PackageReport{}
+	for _, g := range groups {
+		if excludeEmpty && len(g.Exported) == 0 {
+			continue
+		}
+		pkgs = append(pkgs, *g)
+	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Package < pkgs[j].Package })
+	return pkgs
+}
+
+// declaringPackage returns the longest package path that symbol is qualified by.
+func declaringPackage(symbol string, pkgPaths map[string]interface{}) string {
+	for i := strings.LastIndex(symbol, "."); i > 0; i = strings.LastIndex(symbol[:i], ".") {
+		if _, ok := pkgPaths[symbol[:i]]; ok {
+			return symbol[:i]
+		}
+	}
+	return ""
+}
+
+// groupByModule splits the report's exports up by the module that declares
+// them, from the packages' modules. Exports from packages outside of a module
+// are grouped under an empty module.
+func groupByModule(rpt Report, pkgPaths map[string]interface{}, modules map[string]string) []ModuleReport {
+	groups := make(map[string]*ModuleReport)
+	group := func(symbol string) *ModuleReport {
+		mod := modules[declaringPackage(symbol, pkgPaths)]
+		g, ok := groups[mod]
+		if !ok {
+			g = &ModuleReport{Module: mod, Exported: []string{}, UnusedExports: []string{}}
+			groups[mod] = g
+		}
+		return g
+	}
+	for _, symbol := range rpt.Exported {
+		g := group(symbol)
+		g.Exported = append(g.Exported, symbol)
+	}
+	for _, symbol := range rpt.UnusedExports {
+		g := group(symbol)
+		g.UnusedExports = append(g.UnusedExports, symbol)
+	}
+
+	mods := []ModuleReport{}
+	for _, g := range groups {
+		g.Counts = summarize(Report{Exported: g.Exported, UnusedExports: g.UnusedExports})
+		mods = append(mods, *g)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Module < mods[j].Module })
+	return mods
+}
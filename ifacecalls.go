@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"sync"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// creditInterfaceCalls adds a reference to refs for every call, in to, of a
+// method with the name of one of the interface methods in kinds, from a file
+// that imports the interface's package. It's a best-effort stand-in for the
+// type information --precise uses, so it over-approximates: any type's method
+// by that name counts.
+func creditInterfaceCalls(ctx context.Context, to []string, excludeTo []string, tags buildTags, excludeModules moduleFilter, kinds map[string]symbols.Kind, refs map[string]map[string]int) error {
+	// package -> method name -> interface methods by that name
+	methods := make(map[string]map[string][]string)
+	for symbol, kind := range kinds {
+		if kind != symbols.InterfaceMethod {
+			continue
+		}
+		typ := symbol[:strings.LastIndex(symbol, ".")]
+		pkgPath, name := typ[:strings.LastIndex(typ, ".")], symbol[len(typ)+1:]
+		if methods[pkgPath] == nil {
+			methods[pkgPath] = make(map[string][]string)
+		}
+		methods[pkgPath][name] = append(methods[pkgPath][name], symbol)
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	// guards refs, which fn fills from several goroutines
+	var mu sync.Mutex
+
+	fs := token.NewFileSet()
+	err := runOnFiles(ctx, to, excludeTo, func(file string) error {
+		if !tags.matchFile(file) || excludeModules.skip(file) {
+			return nil
+		}
+		f, err := parser.ParseFile(fs, file, nil, 0)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", file, err)
+		}
+		calls := symbols.FindMethodCalls(f)
+		mu.Lock()
+		defer mu.Unlock()
+		for _, pkgPath := range symbols.NewRefVisitor(f).Imports() {
+			for name, n := range calls {
+				for _, symbol := range methods[pkgPath][name] {
+					addRef(refs, symbol, file, n)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find interface calls: %w", err)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 // dummy contains code used in tests.
 package dummy
 
-import "fmt"
+import (
+	"fmt"
+	"net/http"
+)
 
 func ExportedFunction() {
 	fmt.Print("hi")
@@ -15,3 +18,190 @@ type ExportedStruct struct{}
 type ExportedInterface interface {
 	fmt.Stringer
 }
+
+// Less is only ever passed as a value to sort.Slice.
+func Less(i, j int) bool {
+	return i < j
+}
+
+// Handler is only ever passed as a value to http.HandleFunc.
+func Handler(w http.ResponseWriter, r *http.Request) {}
+
+// Greeter's methods are only ever called through an interface.
+type Greeter struct{}
+
+func (g Greeter) Greet() string {
+	return "hi"
+}
+
+// HeaderLen is only ever used to slice.
+const HeaderLen = 4
+
+// Offset is only ever used as an index.
+const Offset = 1
+
+// Ordered is only ever used as a type parameter constraint.
+type Ordered interface {
+	Less(than int) bool
+}
+
+// Step is only ever called inside a labeled loop.
+func Step() bool {
+	return false
+}
+
+// Default is only ever passed to a generic function.
+var Default = 0
+
+// Celsius is only ever used as a type argument.
+type Celsius float64
+
+// StatusOK is only ever used as a map key.
+const StatusOK = 200
+
+// DefaultTimeout is only ever used as a struct field value.
+const DefaultTimeout = 30
+
+// Mode is only ever used as the type of a consumer's constant.
+type Mode int
+
+// ModeFast is only ever used as the value of a consumer's constant.
+const ModeFast Mode = 1
+
+// Ping is only ever stored as a value in a map literal.
+func Ping() {}
+
+// CurrentMode is only ever used as a switch tag.
+func CurrentMode() Mode {
+	return ModeFast
+}
+
+// Value is only ever used in a type switch.
+func Value() interface{} {
+	return nil
+}
+
+// Hook is only ever reassigned.
+var Hook = func() {}
+
+// ConnPool's Release is only ever deferred, on Pool.
+type ConnPool struct{}
+
+func (p *ConnPool) Release() {}
+
+// Pool is only ever used to defer a method call.
+var Pool = &ConnPool{}
+
+// Start is a function with the same name as a method.
+func Start() {}
+
+// Server has methods with value and pointer receivers.
+type Server struct{}
+
+func (s Server) Start() {}
+
+func (s *Server) Stop() {}
+
+const ExportedConstant = 3
+
+// FirstLevel and SecondLevel are a grouped iota block.
+const (
+	FirstLevel = iota
+	SecondLevel
+	unexportedLevel
+)
+
+// Config is only ever used as the type of an anonymous struct's field.
+type Config struct{}
+
+// Inject is only ever called from generated code.
+func Inject() {}
+
+// Elem, Key, Val, Msg, Arg, Result, Constraint and TypeArg are only ever used
+// in type positions.
+type (
+	Elem       struct{}
+	Key        string
+	Val        int
+	Msg        struct{}
+	Arg        struct{}
+	Result     struct{}
+	Constraint interface{ Ping() }
+	TypeArg    struct{}
+)
+
+func (TypeArg) Ping() {}
+
+// Compute's result and Fallback are only ever returned.
+func Compute(x int) int { return x }
+
+var Fallback = 1
+
+// Dotted is only ever used through a dot import.
+func Dotted() {}
+
+// Allocated and Buffer are only ever passed to new and make.
+type Allocated struct{}
+
+type Buffer []byte
+
+// Options' fields are only ever set in keyed literals, except Unset.
+type Options struct {
+	Timeout, Retries int
+	Name             string
+	Unset            bool
+}
+
+// Enabled, First, Max, Setup and Next are only ever used in if and for
+// clauses.
+func Enabled() bool { return true }
+
+const (
+	First = 0
+	Max   = 3
+)
+
+func Setup() error { return nil }
+
+func Next(i int) int { return i + 1 }
+
+// Counted is referenced three times, from two files.
+func Counted() int { return 0 }
+
+// Outer, Inner and their fields are only used through a chain of selectors on
+// consumer values, except Unread.
+type Outer struct {
+	Inner Inner
+	Label string
+}
+
+type Inner struct {
+	Depth  int
+	Unread bool
+}
+
+// Store's Get is called through the interface, but Put never is.
+type Store interface {
+	Get(key string) string
+	Put(key, val string)
+}
+
+// Reader's Read is called on values of the interface type.
+type Reader interface {
+	Read(p []byte) (int, error)
+}
+
+// Appended, Copied and Deleted are only ever passed to append, copy and
+// delete.
+var (
+	Appended = 1
+	Copied   = []int{1, 2}
+)
+
+const Deleted = "key"
+
+// IdxA and IdxB are only ever used as keys of array elements.
+const (
+	IdxA = iota
+	IdxB
+)
@@ -0,0 +1,4 @@
+// noexports is a package without exports, used in tests.
+package noexports
+
+func unexported() {}
@@ -0,0 +1,4 @@
+// dummy is a major version of the dummy package, used in tests.
+package dummy
+
+func ExportedFunction() {}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// packageFiles is a package found in the inputs, with how many of its files
+// were found.
+type packageFiles struct {
+	Package string
+	Files   int
+}
+
+// listPackages returns the packages of the files that would be scanned for
+// exports or references, sorted by import path. Files in both sets count once.
+func listPackages(ctx context.Context, in inputs) ([]packageFiles, error) {
+	pkgs := newPkgResolver()
+	// file -> package
+	files := make(map[string]string)
+	var mu sync.Mutex
+	add := func(file string) {
+		pkgPath := pkgs.pkgPath(file)
+		mu.Lock()
+		defer mu.Unlock()
+		files[file] = pkgPath
+	}
+
+	err := runOnFiles(ctx, in.from, in.excludeFrom, func(file string) error {
+		if in.excludeFile != nil && in.excludeFile.MatchString(file) || !in.tags.matchFile(file) || in.excludeModules.skip(file) {
+			return nil
+		}
+		if isTestFile(file) && !in.includeTests {
+			return nil
+		}
+		add(file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	err = runOnFiles(ctx, in.to, in.excludeTo, func(file string) error {
+		if !in.tags.matchFile(file) || in.excludeModules.skip(file) {
+			return nil
+		}
+		add(file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, pkgPath := range files {
+		counts[pkgPath]++
+	}
+	listed := make([]packageFiles, 0, len(counts))
+	for pkgPath, n := range counts {
+		listed = append(listed, packageFiles{pkgPath, n})
+	}
+	sort.Slice(listed, func(i, j int) bool { return listed[i].Package < listed[j].Package })
+	return listed, nil
+}
+
+// writePackages writes one package per line, with its file count.
+func writePackages(w io.Writer, listed []packageFiles) error {
+	for _, pkg := range listed {
+		if _, err := fmt.Fprintf(w, "%s\t%d\n", pkg.Package, pkg.Files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
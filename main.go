@@ -8,13 +8,20 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"io"
 	"os"
 	"os/signal"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
 
+	"github.com/launchdarkly-labs/refaudit/symbols"
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/tools/go/packages"
 )
@@ -29,95 +36,791 @@ const fromArg = "--from"
 const excludeFromArg = "--exclude-from"
 const toArg = "--to"
 const excludeToArg = "--exclude-to"
+const packagesErrorsArg = "--packages-errors"
+const strictArg = "--strict"
+const countByConsumerArg = "--count-by-consumer"
+const preciseArg = "--precise"
+const groupByArg = "--group-by"
+const excludeEmptyPackagesArg = "--exclude-empty-packages"
+const templateArg = "--template"
+const exportsSinceArg = "--exports-since"
+const assertArg = "--assert"
+const scanDocExamplesArg = "--scan-doc-examples"
+const baselineArg = "--baseline"
+const baselineFormatArg = "--baseline-format"
+const failOnKindArg = "--fail-on-kind"
+const sinceTagArg = "--since-tag"
+const moduleRelativeArg = "--module-relative"
+const sinkArg = "--sink"
+const sinkTimeoutArg = "--sink-timeout"
+const sinkRetriesArg = "--sink-retries"
+const surfaceHashArg = "--surface-hash"
+const excludeFileRegexArg = "--exclude-file-regex"
+const summaryOnlyArg = "--summary-only"
+const testExportsArg = "--test-exports"
+
+// includeTestsArg is the old name of testExportsArg, which was easy to confuse
+// with testRefsArg.
+const includeTestsArg = "--include-tests"
+const trendFileArg = "--trend-file"
+const toTagsArg = "--to-tags"
+const testOnlyExportsArg = "--test-only-exports"
+const sortArg = "--sort"
+const excludeModuleArg = "--exclude-module"
+const verifyArg = "--verify"
+const simulateRemoveArg = "--simulate-remove"
+const maxDepthArg = "--max-depth"
+const exitCodeCountArg = "--exit-code-count"
+const singleConsumerArg = "--single-consumer"
+const preciseKindsArg = "--precise-kinds"
+const facadeArg = "--facade"
+const fieldsArg = "--fields"
+const apiSpecArg = "--api-spec"
+const enforceSpecArg = "--enforce-spec"
+const fromArchiveArg = "--from-archive"
+const toArchiveArg = "--to-archive"
+const gitAgeArg = "--git-age"
+const positionsArg = "--positions"
+const formatArg = "--format"
+const fixArg = "--fix"
+const failOnUnusedArg = "--fail-on-unused"
+const toDepsArg = "--to-deps"
+const generatedOnlyUsageArg = "--generated-only-usage"
+const jobsArg = "--jobs"
+const listPackagesArg = "--list-packages"
+const refManifestArg = "--ref-manifest"
+const manifestKeyArg = "--manifest-key"
+const blankImportsArg = "--blank-imports"
+const maxFileSizeArg = "--max-file-size"
+const buildTagsArg = "--build-tags"
+const testRefsArg = "--test-refs"
+const baselinePositionsArg = "--baseline-positions"
+const jsonSchemaArg = "--json-schema"
+const withUsagesArg = "--with-usages"
+const packagesJSONArg = "--packages-json"
+const referencedByArg = "--referenced-by"
+const excludeGlobArg = "--exclude-glob"
+const interfaceGranularityArg = "--interface-granularity"
+const profilePackagesArg = "--profile-packages"
+const stdinPathArg = "--stdin-path"
+const refRegexArg = "--ref-regex"
+const toGroupArg = "--to-group"
+const requireConsumerGroupArg = "--require-consumer-group"
+const versionArg = "--version"
+
+// unusedExitCode is returned when unused exports are treated as a failure.
+const unusedExitCode = 3
+
+// countExitCodeBase is added to the count for --exit-code-count, to keep it
+// clear of the exit codes for errors and failed checks.
+const countExitCodeBase = 10
+
+// maxExitCode caps --exit-code-count, below the codes shells use for signals
+// and missing commands.
+const maxExitCode = 125
 
 type Report struct {
 	Exported      []string
 	Imported      []string
 	UnusedExports []string
+	// LoadDiagnostics lists packages that loaded with errors, whose results may be inaccurate.
+	LoadDiagnostics []string `json:",omitempty"`
+	// ConsumerCoupling lists consumer packages by how many exports they reference, most coupled first.
+	ConsumerCoupling []Coupling `json:",omitempty"`
+	// SingleConsumerExports lists exports that only one consumer package references.
+	SingleConsumerExports []SingleConsumer `json:",omitempty"`
+	// ReferencedBy maps referenced exports to the consumer packages that
+	// reference them, with --referenced-by.
+	ReferencedBy map[string][]string `json:",omitempty"`
+	// ConsumerGroupOrphans lists the exports that no file in the required
+	// consumer group references, with --require-consumer-group.
+	ConsumerGroupOrphans []string `json:",omitempty"`
+	// InterfaceUsed lists methods that are never called directly, but may be called through an interface.
+	InterfaceUsed []string `json:",omitempty"`
+	// Packages groups exports by the package that declares them.
+	Packages []PackageReport `json:",omitempty"`
+	// Modules groups exports by the module that declares them.
+	Modules []ModuleReport `json:",omitempty"`
+	// DocumentedUsage lists exports that are used in Example functions or code blocks in comments.
+	DocumentedUsage []string `json:",omitempty"`
+	// ReferenceCounts maps exports to how many times they are referenced. Unused
+	// exports are 0, and so are ones credited another way, like through an interface.
+	ReferenceCounts map[string]int `json:",omitempty"`
+	// Positions maps unused exports to where they are declared, with --positions.
+	Positions map[string]Position `json:",omitempty"`
+	// LastModified maps unused exports to the date their file was last committed, with --git-age.
+	LastModified map[string]string `json:",omitempty"`
+	// NeedsReview lists exports that look unused, but whose name appears in the imports with --verify.
+	NeedsReview []string `json:",omitempty"`
+	// TestOnlyExports lists unused exports that are referenced by their own package's tests.
+	TestOnlyExports []string `json:",omitempty"`
+	// ConflictingExports lists exports declared in more than one place, like
+	// when two from roots have the same package.
+	ConflictingExports []Conflict `json:",omitempty"`
+	// TestOnlyUsage lists unused exports that only test files reference, with
+	// --test-refs separate.
+	TestOnlyUsage []string `json:",omitempty"`
+	// BlankImports lists the packages that are only imported for their side effects.
+	BlankImports []string `json:",omitempty"`
+	// ManifestUsage lists exports that are named in a reference manifest.
+	ManifestUsage []string `json:",omitempty"`
+	// PossiblyReflected lists exports whose name a --ref-regex captured from a
+	// string literal, which count as used.
+	PossiblyReflected []string `json:",omitempty"`
+	// GeneratedOnlyUsage lists exports that are only referenced by generated files.
+	GeneratedOnlyUsage []string `json:",omitempty"`
+	// APISpec compares Exported with an API spec.
+	APISpec *SpecDiff `json:",omitempty"`
+	// Fix lists the unused vars and consts that were deleted, and the ones that need to be by hand, with --fix.
+	Fix *FixResult `json:",omitempty"`
+	// Baseline compares UnusedExports with a previous run.
+	Baseline *BaselineDiff `json:",omitempty"`
+	// SimulatedRemoval lists the exports that would become unused without a consumer, with --simulate-remove.
+	SimulatedRemoval *RemovalDiff `json:",omitempty"`
+	// SurfaceHash is a sha256 of the exports and their kinds, which changes whenever they do.
+	SurfaceHash string `json:",omitempty"`
+	// SinceTag compares the exports and UnusedExports with a git tag.
+	SinceTag *SurfaceDiff `json:",omitempty"`
+}
+
+// PackageReport lists the exports of a single package.
+type PackageReport struct {
+	Package       string
+	Exported      []string
+	UnusedExports []string
+}
+
+// ModuleReport lists the exports of a single module.
+type ModuleReport struct {
+	Module        string
+	Exported      []string
+	UnusedExports []string
+	Counts        Summary
+}
+
+// Summary counts the exports in a report, without naming any.
+type Summary struct {
+	Exported   int `json:"exported"`
+	Referenced int `json:"referenced"`
+	Unused     int `json:"unused"`
+}
+
+// Coupling counts the distinct exports a consumer package references.
+type Coupling struct {
+	Consumer string
+	Exports  int
+}
+
+// Position is where an export is declared.
+type Position struct {
+	File string
+	Line int
+}
+
+// Conflict is an export that is declared in more than one place.
+type Conflict struct {
+	Export       string
+	Declarations []Position
+}
+
+// SingleConsumer is an export with the only consumer package that references it.
+type SingleConsumer struct {
+	Export   string
+	Consumer string
 }
 
 func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
-	defer cancel()
-
-	// parse input
-	from := []string{}
-	excludeFrom := []string{}
-	to := []string{}
-	excludeTo := []string{}
-	addArg := func(arg string) {}
-	for _, a := range os.Args[1:] {
-		switch a {
-		case fromArg:
-			addArg = func(arg string) { from = append(from, expandPath(arg)) }
-		case excludeFromArg:
-			addArg = func(arg string) { excludeFrom = append(excludeFrom, expandPath(arg)) }
-		case toArg:
-			addArg = func(arg string) { to = append(to, expandPath(arg)) }
-		case excludeToArg:
-			addArg = func(arg string) { excludeTo = append(excludeTo, expandPath(arg)) }
-		default:
-			addArg(a)
+	out := &syncOutput{}
+	stdout, stderr := out.writer(os.Stdout), out.writer(os.Stderr)
+	code := run(ctx, os.Args[1:], stdout, stderr)
+	stdout.Flush()
+	stderr.Flush()
+	cancel()
+	os.Exit(code)
+}
+
+// run runs an audit with the given command line arguments, and returns the exit code.
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == doctorCmd {
+		return doctor(ctx, stdout)
+	}
+	for _, a := range args {
+		if a == versionArg {
+			if err := writeVersion(stdout); err != nil {
+				fmt.Fprintf(stderr, "%v", err)
+				return 2
+			}
+			return 0
+		}
+	}
+
+	cfg, err := parseArgs(args, stderr)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+	if cfg.jsonSchema {
+		outB, err := json.MarshalIndent(reportSchema(), "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to marshal schema: %v", err)
+			return 2
+		}
+		fmt.Fprintln(stdout, string(outB))
+		return 0
+	}
+	// audit archives like directories, once they're extracted
+	archived := newArchives()
+	defer archived.cleanup()
+	for _, file := range cfg.fromArchives {
+		dir, err := archived.extract(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
 		}
+		cfg.from = append(cfg.from, dir)
 	}
+	for _, file := range cfg.toArchives {
+		dir, err := archived.extract(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		cfg.to = append(cfg.to, dir)
+	}
+
+	for _, pkg := range cfg.toDeps {
+		files, err := depFiles(ctx, pkg)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		cfg.to = append(cfg.to, files...)
+	}
+	for _, file := range cfg.packagesJSON {
+		files, err := packagesJSONFiles(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		cfg.to = append(cfg.to, files...)
+	}
+
 	// validate input
-	if len(from) == 0 && len(to) == 0 {
-		fmt.Println("Find potentially unused exports in go code. Works across repos. There will be false positives.")
-		fmt.Printf("Usage:\n\trefaudit %s [files] %s [files]\n", fromArg, toArg)
-		fmt.Printf("%s: Directories that contain exports.\n", fromArg)
-		fmt.Printf("%s: Directories that contain imports.\n", toArg)
-		fmt.Printf("%s: Directories that contain imports that you want to exclude. Optional.\n", excludeToArg)
-		fmt.Printf("%s: Directories that contain exports that you want to exclude. Optional.\n", excludeFromArg)
-		fmt.Println("Examples:")
-		fmt.Printf("\trefaudit %s /path/to/library/ %s /path/to/app1 /path/to/app2 | tee ~/unused1.json\n", fromArg, toArg)
-		fmt.Printf("\trefaudit %s /path/to/library/ %s /path/to/app1 %s /path/to/app1/exclude | tee ~/unused2.json\n", fromArg, toArg, excludeToArg)
-		os.Exit(1)
+	if len(cfg.from) == 0 && len(cfg.to) == 0 && !cfg.fromStdin && !cfg.toStdin {
+		writeUsage(stdout)
+		return 1
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return 1
+	}
+
+	failKinds, err := parseKinds(cfg.failOnKind)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid %s value: %v\n", failOnKindArg, err)
+		return 1
+	}
+	var typedKinds map[symbols.Kind]interface{}
+	if cfg.preciseKinds != "" {
+		if typedKinds, err = parseKinds(cfg.preciseKinds); err != nil {
+			fmt.Fprintf(stderr, "invalid %s value: %v\n", preciseKindsArg, err)
+			return 1
+		}
+	}
+	namePatterns := []*regexp.Regexp{}
+	for _, pattern := range cfg.refRegexes {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			fmt.Fprintf(stderr, "invalid %s value: %v\n", refRegexArg, err)
+			return 1
+		}
+		if re.NumSubexp() == 0 {
+			fmt.Fprintf(stderr, "invalid %s value: %s has no group to capture the export's name\n", refRegexArg, pattern)
+			return 1
+		}
+		namePatterns = append(namePatterns, re)
+	}
+	stdinFS := token.NewFileSet()
+	var stdinFile *ast.File
+	if cfg.fromStdin || cfg.toStdin {
+		if stdinFile, err = parseStdin(stdinFS, stdin); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+	}
+	sinkTimeoutDuration, err := time.ParseDuration(cfg.sinkTimeout)
+	if err != nil {
+		fmt.Fprintf(stderr, "invalid %s value: %v\n", sinkTimeoutArg, err)
+		return 1
+	}
+	sinkRetriesCount, err := strconv.Atoi(cfg.sinkRetries)
+	if err != nil || sinkRetriesCount < 0 {
+		fmt.Fprintf(stderr, "invalid %s value: %s\n", sinkRetriesArg, cfg.sinkRetries)
+		return 1
+	}
+	if cfg.maxDepth != "" {
+		depth, err := strconv.Atoi(cfg.maxDepth)
+		if err != nil || depth < 0 {
+			fmt.Fprintf(stderr, "invalid %s value: %s\n", maxDepthArg, cfg.maxDepth)
+			return 1
+		}
+		ctx = withMaxDepth(ctx, depth)
+	}
+	if cfg.maxFileSize != "" {
+		size, err := strconv.ParseInt(cfg.maxFileSize, 10, 64)
+		if err != nil || size < 0 {
+			fmt.Fprintf(stderr, "invalid %s value: %s\n", maxFileSizeArg, cfg.maxFileSize)
+			return 1
+		}
+		ctx = withMaxFileSize(ctx, size, stderr)
+	}
+	for _, pattern := range cfg.excludeGlobs {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			fmt.Fprintf(stderr, "invalid %s value: %s\n", excludeGlobArg, pattern)
+			return 1
+		}
+	}
+	if len(cfg.excludeGlobs) > 0 {
+		ctx = withExcludeGlobs(ctx, cfg.excludeGlobs)
+	}
+	interfaceMethods := cfg.interfaceGranularity == "method"
+	jobsCount, err := strconv.Atoi(cfg.jobs)
+	if err != nil || jobsCount < 1 {
+		fmt.Fprintf(stderr, "invalid %s value: %s\n", jobsArg, cfg.jobs)
+		return 1
+	}
+	ctx = withJobs(ctx, jobsCount)
+	if cfg.profilePackages {
+		profile := newLoadProfile()
+		ctx = withLoadProfile(ctx, profile)
+		defer profile.write(stderr)
+	}
+	var excludeFile *regexp.Regexp
+	if cfg.excludeFileRegex != "" {
+		if excludeFile, err = regexp.Compile(cfg.excludeFileRegex); err != nil {
+			fmt.Fprintf(stderr, "invalid %s value: %v\n", excludeFileRegexArg, err)
+			return 1
+		}
+	}
+	var tmpl *template.Template
+	if cfg.templateFile != "" {
+		tmpl, err = parseTemplate(cfg.templateFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	var baseline map[string]interface{}
+	var baselinePos map[string]Position
+	if cfg.baselineFile != "" {
+		baseline, err = loadBaseline(cfg.baselineFile, cfg.baselineFormat)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+	}
+	if cfg.baselinePositions {
+		if baselinePos, err = loadBaselinePositions(cfg.baselineFile); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	var apiSpec map[string]interface{}
+	if cfg.apiSpecFile != "" {
+		apiSpec, err = loadSpec(cfg.apiSpecFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+	}
+
+	var rel relativizer
+	if cfg.moduleRelative {
+		if rel, err = newRelativizer(cfg.from); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
 	}
 
 	// print input  so user knows what's going on
-	fmt.Fprintf(os.Stderr, "%s: %s\n", fromArg, strings.Join(from, ", "))
-	fmt.Fprintf(os.Stderr, "%s: %s\n", toArg, strings.Join(to, ", "))
-	fmt.Fprintf(os.Stderr, "%s: %s\n", excludeToArg, strings.Join(excludeTo, ", "))
-	fmt.Fprintf(os.Stderr, "%s: %s\n", excludeFromArg, strings.Join(excludeFrom, ", "))
+	fmt.Fprintf(stderr, "%s: %s\n", fromArg, strings.Join(cfg.from, ", "))
+	fmt.Fprintf(stderr, "%s: %s\n", toArg, strings.Join(cfg.to, ", "))
+	fmt.Fprintf(stderr, "%s: %s\n", excludeToArg, strings.Join(cfg.excludeTo, ", "))
+	fmt.Fprintf(stderr, "%s: %s\n", excludeFromArg, strings.Join(cfg.excludeFrom, ", "))
 
-	globals, err := findExports(ctx, from, excludeFrom)
+	modules := newModuleFilter(cfg.excludeModules)
+	if cfg.listPkgs {
+		listed, err := listPackages(ctx, inputs{cfg.from, cfg.excludeFrom, cfg.to, cfg.excludeTo, excludeFile, modules, cfg.includeTests, cfg.fields, interfaceMethods, cfg.tags})
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+		if err := writePackages(stdout, listed); err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+		return 0
+	}
+
+	scan, err := findExports(ctx, cfg.from, cfg.excludeFrom, excludeFile, cfg.tags, modules, cfg.includeTests, cfg.excludeEmptyPackages)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v", err)
-		os.Exit(2)
+		fmt.Fprintf(stderr, "%v", err)
+		return 2
+	}
+	if cfg.fromStdin {
+		scan.addFile(stdinFS, stdinFile, cfg.stdinPath)
+	}
+	if !cfg.fields {
+		scan.drop(symbols.Field)
+	}
+	if !interfaceMethods {
+		scan.drop(symbols.InterfaceMethod)
+	}
+	if cfg.exportsSince != "" {
+		if err := filterExportsSince(ctx, cfg.from, cfg.exportsSince, scan); err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
 	}
+	globals, diagnostics := scan.exports, scan.diagnostics
 
-	refs, err := findImports(ctx, to, excludeTo)
+	refs, err := findImports(ctx, cfg.to, cfg.excludeTo, cfg.tags, modules)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "%v", err)
-		os.Exit(2)
+		fmt.Fprintf(stderr, "%v", err)
+		return 2
+	}
+	if len(cfg.facades) > 0 {
+		facadeRefs, err := findImports(ctx, cfg.facades, cfg.excludeTo, cfg.tags, modules)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+		for symbol, files := range facadeRefs {
+			for file, n := range files {
+				addRef(refs, symbol, file, n)
+			}
+		}
+	}
+	if cfg.toStdin {
+		for symbol, n := range symbols.CountRefs(stdinFile) {
+			addRef(refs, symbol, stdinName, n)
+		}
+	}
+
+	ifaceUses := map[string]interface{}{}
+	if cfg.precise {
+		uses, err := findTypedUses(ctx, cfg.to, cfg.excludeTo, cfg.tags, modules, typedKinds)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+		ifaceUses = uses.interfaces
+		for _, d := range uses.diagnostics {
+			diagnostics = sortedInsert(diagnostics, d)
+		}
+		uses.replace(refs)
+		for symbol, files := range uses.methods {
+			for file, n := range files {
+				addRef(refs, symbol, file, n)
+			}
+		}
+	} else if interfaceMethods {
+		if err := creditInterfaceCalls(ctx, cfg.to, cfg.excludeTo, cfg.tags, modules, scan.kinds, refs); err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+	if cfg.strict && len(diagnostics) > 0 {
+		fmt.Fprintf(stderr, "packages loaded with errors:\n%s\n", strings.Join(diagnostics, "\n"))
+		return 2
+	}
+
+	testOnlyRefs := map[string]interface{}{}
+	if cfg.testRefs != "include" {
+		testOnlyRefs = dropTestRefs(refs)
+	}
+
+	docUses := map[string]interface{}{}
+	if cfg.scanDocExamples {
+		docUses, err = findDocUsages(ctx, cfg.from, cfg.excludeFrom)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+
+	testUses := map[string]interface{}{}
+	if cfg.testOnlyExports {
+		testUses, err = findTestUses(ctx, cfg.from, cfg.excludeFrom)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+
+	manifestUses := map[string]interface{}{}
+	if len(cfg.refManifests) > 0 {
+		names, err := manifestNames(cfg.refManifests, cfg.manifestKey)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 1
+		}
+		manifestUses = manifestUsage(globals, scan.packages, names)
+	}
+
+	reflectedUses := map[string]interface{}{}
+	if len(namePatterns) > 0 {
+		names, err := findRegexNames(ctx, cfg.to, cfg.excludeTo, cfg.tags, modules, namePatterns)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+		reflectedUses = manifestUsage(globals, scan.packages, names)
 	}
 
 	// print potentially unused globals
-	rpt := Report{
-		Exported:      []string{},
-		Imported:      []string{},
-		UnusedExports: []string{},
+	rpt := newReport(globals, refs, credits{ifaceUses, docUses, manifestUses, reflectedUses})
+	if cfg.verify {
+		found, err := verifyUnused(ctx, cfg.to, cfg.excludeTo, rpt.UnusedExports, scan.positions)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+		rpt.UnusedExports, rpt.NeedsReview = needsReview(rpt.UnusedExports, found)
 	}
-	for k := range globals {
-		rpt.Exported = sortedInsert(rpt.Exported, k)
-		//rpt.Exported = append(rpt.Exported, k)
-		if _, ok := refs[k]; !ok {
-			rpt.UnusedExports = sortedInsert(rpt.UnusedExports, k)
+	for _, k := range rpt.UnusedExports {
+		if _, ok := testUses[k]; ok {
+			rpt.TestOnlyExports = append(rpt.TestOnlyExports, k)
+		}
+		if _, ok := testOnlyRefs[k]; ok && cfg.testRefs == "separate" {
+			rpt.TestOnlyUsage = append(rpt.TestOnlyUsage, k)
 		}
 	}
-	for k := range refs {
-		rpt.Imported = sortedInsert(rpt.Imported, k)
+	if cfg.blankImports {
+		if rpt.BlankImports, err = findBlankImports(ctx, cfg.to, cfg.excludeTo, cfg.tags, modules); err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+	if cfg.generatedOnly {
+		if rpt.GeneratedOnlyUsage, err = generatedOnlyUsage(globals, refs); err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+	rpt.ConflictingExports = conflictingExports(scan.conflicts)
+	if cfg.packagesErrors {
+		rpt.LoadDiagnostics = diagnostics
+	}
+	// the consumer analyses resolve the same files
+	consumerPkgs := newPkgResolver()
+	if cfg.countByConsumer {
+		rpt.ConsumerCoupling = consumerCoupling(consumerPkgs, globals, refs)
+	}
+	if cfg.singleConsumer {
+		rpt.SingleConsumerExports = singleConsumerExports(consumerPkgs, globals, refs)
+	}
+	if cfg.withReferencedBy {
+		rpt.ReferencedBy = referencedBy(consumerPkgs, globals, refs)
+	}
+	if cfg.requiredGroup != "" {
+		rpt.ConsumerGroupOrphans = groupOrphans(globals, refs, cfg.consumerGroups[cfg.requiredGroup])
+	}
+	if cfg.groupBy == "package" {
+		rpt.Packages = groupByPackage(rpt, scan.packages, cfg.excludeEmptyPackages)
+	}
+	if cfg.groupBy == "module" {
+		rpt.Modules = groupByModule(rpt, scan.packages, scan.modules)
+	}
+	if cfg.surface {
+		rpt.SurfaceHash = surfaceHash(rpt.Exported, scan.kinds)
+	}
+	if apiSpec != nil {
+		rpt.APISpec = diffSpec(apiSpec, rpt.Exported)
+	}
+	if cfg.baselinePositions {
+		current := unusedPositions(rpt.UnusedExports, scan.positions)
+		rpt.Baseline = diffBaselinePositions(baseline, baselinePos, rpt.UnusedExports, current)
+	} else if baseline != nil {
+		rpt.Baseline = diffBaseline(baseline, rpt.UnusedExports)
+	}
+	if cfg.sinceTag != "" {
+		rpt.SinceTag, err = surfaceSince(ctx, cfg.sinceTag, inputs{cfg.from, cfg.excludeFrom, cfg.to, cfg.excludeTo, excludeFile, modules, cfg.includeTests, cfg.fields, interfaceMethods, cfg.tags}, rpt)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
 	}
 
-	outB, err := json.MarshalIndent(rpt, "", "  ")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "failed to marshal output: %v", err)
-		os.Exit(2)
+	if cfg.fix {
+		rpt.Fix, err = fixUnused(ctx, rpt.UnusedExports, scan.kinds, scan.positions, cfg.tags)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+	if cfg.positions {
+		rpt.Positions = unusedPositions(rpt.UnusedExports, scan.positions)
 	}
-	fmt.Println(string(outB))
+	if cfg.gitAge {
+		rpt.LastModified, err = lastModified(ctx, rpt.UnusedExports, scan.positions)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+	if cfg.simulateRemove != "" {
+		rpt.SimulatedRemoval, err = simulateRemoval(ctx, cfg.simulateRemove, inputs{cfg.from, cfg.excludeFrom, cfg.to, cfg.excludeTo, excludeFile, modules, cfg.includeTests, cfg.fields, interfaceMethods, cfg.tags}, rpt)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v", err)
+			return 2
+		}
+	}
+
+	if cfg.sortBy == "location" {
+		sortByLocation(rpt.UnusedExports, scan.positions)
+	}
+	failing := unusedOfKinds(rpt.UnusedExports, scan.kinds, failKinds)
+	var rows [][]string
+	if cfg.format == "csv" {
+		rows = unusedRows(rpt.UnusedExports, scan.kinds, scan.positions)
+	}
+	var usages []ImportedUsage
+	if cfg.withUsages {
+		usages = importedUsages(rpt.Imported, refs)
+	}
+	if cfg.moduleRelative {
+		rel.report(&rpt)
+		for i := range usages {
+			usages[i].Symbol = rel.symbol(usages[i].Symbol)
+		}
+		// skip the header
+		for i := 1; i < len(rows); i++ {
+			rows[i][0] = rel.symbol(rows[i][0])
+		}
+	}
+
+	if cfg.sink != "" {
+		outB, err := marshalReport(rpt, usages)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to marshal output: %v", err)
+			return 2
+		}
+		if err := postReport(ctx, cfg.sink, outB, sinkTimeoutDuration, sinkRetriesCount); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 2
+		}
+	}
+
+	if cfg.trendFile != "" {
+		if err := appendTrend(cfg.trendFile, rpt, time.Now()); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return 2
+		}
+	}
+
+	if cfg.assertMessage != "" {
+		return assertNoUnused(stdout, cfg.assertMessage, rpt)
+	}
+
+	if err := writeReport(stdout, cfg, tmpl, rpt, usages, rows); err != nil {
+		fmt.Fprintf(stderr, "%v", err)
+		return 2
+	}
+	return exitCode(stderr, cfg, rpt, failing)
+}
+
+// countExitCode returns the exit code for --exit-code-count, which is offset
+// so that a count can't be mistaken for an error.
+func countExitCode(unused int) int {
+	if unused == 0 {
+		return 0
+	}
+	if countExitCodeBase+unused > maxExitCode {
+		return maxExitCode
+	}
+	return countExitCodeBase + unused
+}
+
+// parseKinds parses a comma separated list of export kinds.
+func parseKinds(list string) (map[symbols.Kind]interface{}, error) {
+	kinds := make(map[symbols.Kind]interface{})
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, kind := range symbols.Kinds {
+			if string(kind) == name {
+				kinds[kind] = exists
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown kind %s, expected one of %s", name, kindList(symbols.Kinds))
+		}
+	}
+	return kinds, nil
 }
 
-// sortedInsert
+// kindList joins kinds with commas.
+func kindList(kinds []symbols.Kind) string {
+	names := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		names = append(names, string(kind))
+	}
+	return strings.Join(names, ",")
+}
+
+// unusedOfKinds returns the unused exports that are of one of kinds.
+func unusedOfKinds(unused []string, declared map[string]symbols.Kind, kinds map[symbols.Kind]interface{}) []string {
+	found := []string{}
+	for _, symbol := range unused {
+		if _, ok := kinds[declared[symbol]]; ok {
+			found = append(found, symbol)
+		}
+	}
+	return found
+}
+
+// assertNoUnused prints whether the report has no unused exports, along with
+// message and any that were found, and returns the exit code to use.
+func assertNoUnused(w io.Writer, message string, rpt Report) int {
+	if len(rpt.UnusedExports) == 0 {
+		fmt.Fprintf(w, "PASS: %s\n", message)
+		return 0
+	}
+	fmt.Fprintf(w, "FAIL: %s\n", message)
+	fmt.Fprintf(w, "%d unused exports:\n", len(rpt.UnusedExports))
+	for _, symbol := range rpt.UnusedExports {
+		fmt.Fprintf(w, "\t%s\n", symbol)
+	}
+	return unusedExitCode
+}
+
+// sortByLocation sorts symbols by the file and line they are declared on, then
+// by name. Symbols without a known position go last.
+func sortByLocation(list []string, positions map[string]token.Position) {
+	sort.SliceStable(list, func(i, j int) bool {
+		pi, iok := positions[list[i]]
+		pj, jok := positions[list[j]]
+		switch {
+		case iok != jok:
+			return iok
+		case pi.Filename != pj.Filename:
+			return pi.Filename < pj.Filename
+		case pi.Line != pj.Line:
+			return pi.Line < pj.Line
+		}
+		return list[i] < list[j]
+	})
+}
+
+// sortedInsert inserts elem into the sorted list, unless it's already in it.
 func sortedInsert(list []string, elem string) []string {
 	// find spot to insert element
 	i := sort.Search(len(list), func(i int) bool { return list[i] >= elem })
@@ -125,8 +828,13 @@ func sortedInsert(list []string, elem string) []string {
 	if i == len(list) {
 		return append(list, elem)
 	}
-	// shift over and set
-	list = append(list[:i+1], list[i:]...)
+	// already there
+	if list[i] == elem {
+		return list
+	}
+	// grow, shift over and set
+	list = append(list, "")
+	copy(list[i+1:], list[i:])
 	list[i] = elem
 	return list
 }
@@ -140,34 +848,120 @@ func expandPath(path string) string {
 	return exp
 }
 
-// runOnFiles runs fn on every file/dir specified, recursively.
+// maxDepthKey is the context key for how deep runOnFiles walks.
+type maxDepthKey struct{}
+
+// withMaxDepth returns a context in which runOnFiles walks at most depth
+// directory levels below each root.
+func withMaxDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, maxDepthKey{}, depth)
+}
+
+// fileSizeKey is the context key for the fileSizeLimit runOnFiles applies.
+type fileSizeKey struct{}
+
+// fileSizeLimit skips files that are too large, warning about each once.
+type fileSizeLimit struct {
+	max      int64
+	warnings io.Writer
+	// guards warned
+	mu *sync.Mutex
+	// path -> exists
+	warned map[string]interface{}
+}
+
+// withMaxFileSize returns a context in which runOnFiles skips files larger
+// than max bytes, writing a warning to warnings the first time each is skipped.
+func withMaxFileSize(ctx context.Context, max int64, warnings io.Writer) context.Context {
+	return context.WithValue(ctx, fileSizeKey{}, fileSizeLimit{max, warnings, &sync.Mutex{}, make(map[string]interface{})})
+}
+
+// skip reports whether a file of size bytes at path is too large.
+func (l fileSizeLimit) skip(path string, size int64) bool {
+	if size <= l.max {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.warned[path]; !ok {
+		l.warned[path] = exists
+		fmt.Fprintf(l.warnings, "warning: skipping %s, its %d bytes are over the %d byte limit\n", path, size, l.max)
+	}
+	return true
+}
+
+// excludeGlobsKey is the context key for the patterns of file names runOnFiles
+// skips.
+type excludeGlobsKey struct{}
+
+// withExcludeGlobs returns a context in which runOnFiles skips files whose base
+// name matches any of patterns, as in filepath.Match.
+func withExcludeGlobs(ctx context.Context, patterns []string) context.Context {
+	return context.WithValue(ctx, excludeGlobsKey{}, patterns)
+}
+
+// matchesAny reports whether name matches any of patterns. Bad patterns match
+// nothing.
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// jobsKey is the context key for how many files runOnFiles handles at once.
+type jobsKey struct{}
+
+// withJobs returns a context in which runOnFiles runs fn on up to jobs files
+// concurrently.
+func withJobs(ctx context.Context, jobs int) context.Context {
+	return context.WithValue(ctx, jobsKey{}, jobs)
+}
+
+// runOnFiles runs fn on every file/dir specified, recursively, down to the
+// depth set with withMaxDepth if any, skipping files over the size set with
+// withMaxFileSize if any, and files named like the patterns set with
+// withExcludeGlobs. fn is called from as many goroutines as set with
+// withJobs, or just one by default.
 func runOnFiles(ctx context.Context, files []string, excluding []string, fn func(file string) error) error {
 	g, ctx := errgroup.WithContext(ctx)
 	filesChan := make(chan string, 4) // buffered chan since walking can take a while
 
-	// set up file consumer
-	g.Go(func() error {
-		for {
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case f, ok := <-filesChan:
-				if ok {
-					if err := fn(f); err != nil {
-						return err
+	// set up file consumers
+	jobs, ok := ctx.Value(jobsKey{}).(int)
+	if !ok || jobs < 1 {
+		jobs = 1
+	}
+	for i := 0; i < jobs; i++ {
+		g.Go(func() error {
+			for {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case f, ok := <-filesChan:
+					if ok {
+						if err := fn(f); err != nil {
+							return err
+						}
+					} else {
+						return nil
 					}
-				} else {
-					return nil
 				}
 			}
-		}
-	})
+		})
+	}
 
 	// walk the dir tree, producing files
 	g.Go(func() error {
 		defer close(filesChan)
 		vendor := fmt.Sprintf("%svendor%s", fsep, fsep)
-		for _, file := range files {
+		maxDepth, limited := ctx.Value(maxDepthKey{}).(int)
+		sizeLimit, sizeLimited := ctx.Value(fileSizeKey{}).(fileSizeLimit)
+		globs, _ := ctx.Value(excludeGlobsKey{}).([]string)
+		for _, file := range dedupeRoots(files) {
+			root := file
 			err := filepath.Walk(file,
 				func(path string, info os.FileInfo, err error) error {
 					if err != nil {
@@ -180,9 +974,16 @@ func runOnFiles(ctx context.Context, files []string, excluding []string, fn func
 					if strings.Contains(path, vendor) {
 						return filepath.SkipDir
 					}
-					// exclude any top-level paths as needed
-					for _, ex := range excluding {
-						if strings.TrimSuffix(path, fsep) == strings.TrimSuffix(ex, fsep) {
+					// exclude the excluded paths and everything under them
+					if isExcluded(path, excluding) {
+						if info.IsDir() {
+							return filepath.SkipDir
+						}
+						return nil
+					}
+					// don't descend past the max depth
+					if limited && info.IsDir() && path != root {
+						if rel, err := filepath.Rel(root, path); err == nil && strings.Count(rel, fsep)+1 > maxDepth {
 							return filepath.SkipDir
 						}
 					}
@@ -194,6 +995,14 @@ func runOnFiles(ctx context.Context, files []string, excluding []string, fn func
 					if !strings.HasSuffix(path, ".go") {
 						return nil
 					}
+					// don't run on files excluded by name, like mocks
+					if matchesAny(filepath.Base(path), globs) {
+						return nil
+					}
+					// don't run on files too large to parse
+					if sizeLimited && sizeLimit.skip(path, info.Size()) {
+						return nil
+					}
 					// send to consumer
 					filesChan <- path
 
@@ -209,120 +1018,233 @@ func runOnFiles(ctx context.Context, files []string, excluding []string, fn func
 	return g.Wait()
 }
 
-func findExports(ctx context.Context, from []string, excludeFrom []string) (map[string]interface{}, error) {
+// dedupeRoots sorts paths and drops duplicates and paths inside another one, so
+// that every file is only walked once.
+func dedupeRoots(paths []string) []string {
+	sorted := make([]string, 0, len(paths))
+	for _, p := range paths {
+		sorted = append(sorted, filepath.Clean(p))
+	}
+	sort.Strings(sorted)
+
+	roots := []string{}
+	for _, p := range sorted {
+		if !isExcluded(p, roots) {
+			roots = append(roots, p)
+		}
+	}
+	return roots
+}
+
+// exportScan is what findExports found in the --from directories.
+type exportScan struct {
+	// symbol -> exists
+	exports map[string]interface{}
+	// symbol -> where it is declared
+	positions map[string]token.Position
+	// symbol -> what it is declared as
+	kinds map[string]symbols.Kind
+	// import path of every package scanned -> exists
+	packages map[string]interface{}
+	// import path of every package scanned -> path of its module, if it's in one
+	modules map[string]string
+	// errors reported while loading packages, sorted
+	diagnostics []string
+	// symbol -> every place it is declared, if that's more than one
+	conflicts map[string][]token.Position
+}
+
+// inputs are what an audit runs on, for when it has to be run again.
+type inputs struct {
+	from, excludeFrom, to, excludeTo []string
+	excludeFile                      *regexp.Regexp
+	excludeModules                   moduleFilter
+	includeTests                     bool
+	fields                           bool
+	interfaceMethods                 bool
+	tags                             buildTags
+}
+
+// drop removes the exports of kind from the scan.
+func (scan exportScan) drop(kind symbols.Kind) {
+	for symbol, k := range scan.kinds {
+		if k == kind {
+			delete(scan.exports, symbol)
+			delete(scan.positions, symbol)
+			delete(scan.kinds, symbol)
+		}
+	}
+}
+
+// findExports returns the exported symbols found in from, ignoring files whose
+// path matches excludeFile if it is set, whose build constraints aren't
+// satisfied with tags, or that are in excluded modules. Exports in test files are only found
+// if includeTests is set. If skipEmpty is set, files that export nothing are
+// not loaded, so export-less packages are left out.
+func findExports(ctx context.Context, from []string, excludeFrom []string, excludeFile *regexp.Regexp, tags buildTags, excludeModules moduleFilter, includeTests bool, skipEmpty bool) (exportScan, error) {
 	globals := make(map[string]interface{})
+	positions := make(map[string]token.Pos)
+	kinds := make(map[string]symbols.Kind)
+	pkgPaths := make(map[string]interface{})
+	modules := make(map[string]string)
+	diagnostics := make(map[string]interface{})
+	conflicts := make(map[string][]token.Pos)
+	// guards the maps, which fn fills from several goroutines
+	var mu sync.Mutex
 
 	fs := token.NewFileSet()
 	err := runOnFiles(ctx, from, excludeFrom, func(file string) error {
+		if excludeFile != nil && excludeFile.MatchString(file) || !tags.matchFile(file) || excludeModules.skip(file) {
+			return nil
+		}
 		f, err := parser.ParseFile(fs, file, nil, parser.AllErrors)
 		if err != nil {
 			return fmt.Errorf("could not parse %s: %w", file, err)
 		}
 
+		if skipEmpty && len(symbols.FindExports(f, "")) == 0 {
+			return nil
+		}
+
 		// find the public-facing full package path for the file
-		cfg := &packages.Config{Mode: packages.NeedName, Tests: false, Dir: path.Dir(file)}
+		cfg := &packages.Config{Mode: packages.NeedName | packages.NeedFiles | packages.NeedModule, Tests: includeTests, Dir: path.Dir(file), BuildFlags: tags.buildFlags(file)}
+		start := time.Now()
 		pkgs, err := packages.Load(cfg, fmt.Sprintf("file=%s", file))
 		if err != nil {
 			return fmt.Errorf("could not parse package in %s: %w", file, err)
 		}
-		pkgPath := ""
+		loaded := time.Since(start)
+		mu.Lock()
+		defer mu.Unlock()
 		for _, pkg := range pkgs {
-			if pkg.Name != "" {
-				pkgPath = pkg.PkgPath
+			for _, pkgErr := range pkg.Errors {
+				diagnostics[fmt.Sprintf("%s: %v", pkg.PkgPath, pkgErr)] = exists
 			}
 		}
-		if pkgPath == "" {
+		pkg := packageOf(pkgs, file)
+		if pkg == nil {
 			// probably a test
+			recordLoad(ctx, path.Dir(file), loaded)
 			return nil
 		}
-		pkgPath = strings.Trim(pkgPath, "\"")
+		pkgPath := symbols.NormalizePkgPath(pkg.PkgPath)
+		recordLoad(ctx, pkgPath, loaded)
+		pkgPaths[pkgPath] = exists
+		if pkg.Module != nil {
+			modules[pkgPath] = pkg.Module.Path
+		}
 
 		// scan the file for exports
-		v := newExportVisitor(f, globals, pkgPath)
-		ast.Walk(v, f)
+		for symbol, export := range symbols.FindExports(f, pkgPath) {
+			if previous, ok := positions[symbol]; ok && previous != export.Pos {
+				// the same package is in several from roots, or declares
+				// the symbol twice
+				if len(conflicts[symbol]) == 0 {
+					conflicts[symbol] = []token.Pos{previous}
+				}
+				conflicts[symbol] = append(conflicts[symbol], export.Pos)
+			}
+			globals[symbol] = exists
+			positions[symbol] = export.Pos
+			kinds[symbol] = export.Kind
+		}
 		return nil
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to find exports: %w", err)
+		return exportScan{}, fmt.Errorf("failed to find exports: %w", err)
 	}
-	return globals, nil
-}
-
-// exportVisitor tracks public exports.
-type exportVisitor struct {
-	f       *ast.File
-	pkgPath string
-	exports map[string]interface{}
-}
-
-func newExportVisitor(f *ast.File, exports map[string]interface{}, pkgPath string) exportVisitor {
-	return exportVisitor{f, pkgPath, exports}
-}
-
-func (v exportVisitor) Visit(n ast.Node) ast.Visitor {
-	if n == nil {
-		return nil
+	diags := []string{}
+	for d := range diagnostics {
+		diags = sortedInsert(diags, d)
+	}
+	declarations := make(map[string]token.Position, len(positions))
+	for symbol, pos := range positions {
+		declarations[symbol] = fs.Position(pos)
 	}
+	conflicting := make(map[string][]token.Position, len(conflicts))
+	for symbol, list := range conflicts {
+		for _, pos := range list {
+			conflicting[symbol] = append(conflicting[symbol], fs.Position(pos))
+		}
+		sort.Slice(conflicting[symbol], func(i, j int) bool {
+			a, b := conflicting[symbol][i], conflicting[symbol][j]
+			if a.Filename != b.Filename {
+				return a.Filename < b.Filename
+			}
+			return a.Line < b.Line
+		})
+	}
+	return exportScan{globals, declarations, kinds, pkgPaths, modules, diags, conflicting}, nil
+}
 
-	switch d := n.(type) {
-	case *ast.AssignStmt:
-		if d.Tok != token.DEFINE {
-			return v
+// packageOf returns the package file is compiled into, out of the packages
+// loaded for it. A file in a package with tests is in both the package and its
+// test variant, in which case the package itself wins. A test file is only in
+// the test variant, or in the external test package.
+func packageOf(pkgs []*packages.Package, file string) *packages.Package {
+	var found *packages.Package
+	for _, pkg := range pkgs {
+		// skip the generated test main packages
+		if pkg.Name == "" || strings.HasSuffix(pkg.ID, ".test") || !containsFile(pkg, file) {
+			continue
 		}
-		for _, name := range d.Lhs {
-			v.add(name)
+		if found == nil || isTestVariant(found) {
+			found = pkg
 		}
+	}
+	if found != nil {
+		return found
+	}
 
-	case *ast.FuncDecl:
-		v.add(d.Name)
-	case *ast.GenDecl:
-		if d.Tok == token.VAR {
-			for _, spec := range d.Specs {
-				if value, ok := spec.(*ast.ValueSpec); ok {
-					for _, name := range value.Names {
-						v.add(name)
-					}
-				}
-			}
-		} else if d.Tok == token.TYPE {
-			for _, spec := range d.Specs {
-				if value, ok := spec.(*ast.TypeSpec); ok {
-					v.add(value.Name)
-				}
-			}
+	// packages that failed to load may not list their files
+	for _, pkg := range pkgs {
+		if pkg.Name != "" && !isTestVariant(pkg) && !strings.HasSuffix(pkg.ID, ".test") {
+			found = pkg
 		}
 	}
+	return found
+}
 
-	return v
+// isTestVariant reports whether pkg is a package compiled for its tests, like
+// "example.com/lib [example.com/lib.test]".
+func isTestVariant(pkg *packages.Package) bool {
+	return strings.Contains(pkg.ID, " [")
 }
 
-func (v exportVisitor) add(n ast.Node) {
-	ident, ok := n.(*ast.Ident)
-	if !ok {
-		return
-	}
-	if ident.Name == "_" || ident.Name == "" {
-		return
-	}
-	if ident.Obj != nil && ident.Obj.Pos() == ident.Pos() {
-		if ident.IsExported() {
-			v.exports[v.pkgPath+"."+ident.Name] = exists
+// containsFile reports whether file is one of pkg's go files.
+func containsFile(pkg *packages.Package, file string) bool {
+	for _, f := range append(pkg.GoFiles, pkg.CompiledGoFiles...) {
+		if filepath.Clean(f) == filepath.Clean(file) {
+			return true
 		}
 	}
+	return false
 }
 
-func findImports(ctx context.Context, to []string, excludeTo []string) (map[string]interface{}, error) {
-	refs := make(map[string]interface{})
+// findImports returns the symbols referenced in to, each mapped to the files
+// that reference it and how many times they do. Files are skipped if their build constraints aren't
+// satisfied with their directory's tags, or if they are in excluded modules.
+func findImports(ctx context.Context, to []string, excludeTo []string, tags buildTags, excludeModules moduleFilter) (map[string]map[string]int, error) {
+	refs := make(map[string]map[string]int)
+	// guards refs, which fn fills from several goroutines
+	var mu sync.Mutex
 
 	fs := token.NewFileSet()
 	err := runOnFiles(ctx, to, excludeTo, func(file string) error {
+		if !tags.matchFile(file) || excludeModules.skip(file) {
+			return nil
+		}
 		f, err := parser.ParseFile(fs, file, nil, parser.AllErrors)
 
 		if err != nil {
 			return fmt.Errorf("could not parse %s: %w", file, err)
 		} else {
-			v := newRefVisitor(f, refs)
-			ast.Walk(v, f)
+			found := symbols.CountRefs(f)
+			mu.Lock()
+			defer mu.Unlock()
+			for symbol, n := range found {
+				addRef(refs, symbol, file, n)
+			}
 		}
 		return nil
 	})
@@ -332,53 +1254,26 @@ func findImports(ctx context.Context, to []string, excludeTo []string) (map[stri
 	return refs, nil
 }
 
-// refVisitor tracks import references.
-type refVisitor struct {
-	f    *ast.File
-	refs map[string]interface{}
-	// alias -> real pkg
-	importedPkgs map[string]string
-}
-
-func newRefVisitor(f *ast.File, refs map[string]interface{}) refVisitor {
-	ip := make(map[string]string)
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.IMPORT {
-			continue
-		}
-
-		for _, spec := range genDecl.Specs {
-			importSpec, ok := spec.(*ast.ImportSpec)
-			if ok {
-				impName := strings.Trim(importSpec.Path.Value, "\"")
-				splits := strings.Split(impName, "/")
-				alias := splits[len(splits)-1]
-				if importSpec.Name != nil {
-					alias = importSpec.Name.Name
-				}
-				ip[alias] = impName
-			}
-
-		}
+// addRef records that file references symbol n more times.
+func addRef(refs map[string]map[string]int, symbol string, file string, n int) {
+	files, ok := refs[symbol]
+	if !ok {
+		files = make(map[string]int)
+		refs[symbol] = files
 	}
-
-	return refVisitor{f, refs, ip}
+	files[file] += n
 }
 
-func (v refVisitor) Visit(n ast.Node) ast.Visitor {
-	if n == nil {
-		return nil
-	}
-
-	if d, ok := n.(*ast.SelectorExpr); ok {
-		xIdent, ok := d.X.(*ast.Ident)
-		if !ok {
-			return v
-		}
-		if imp, ok := v.importedPkgs[xIdent.Name]; ok {
-			v.refs[imp+"."+d.Sel.Name] = exists
+// referenceCounts maps each of the exports to how many times refs reference it
+// in total.
+func referenceCounts(exports map[string]interface{}, refs map[string]map[string]int) map[string]int {
+	counts := make(map[string]int, len(exports))
+	for symbol := range exports {
+		total := 0
+		for _, n := range refs[symbol] {
+			total += n
 		}
+		counts[symbol] = total
 	}
-	return v
+	return counts
 }
@@ -0,0 +1,948 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runArgs runs refaudit with args, returning the exit code and stdout.
+func runArgs(t *testing.T, args ...string) (int, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	code := run(context.TODO(), args, stdout, stderr)
+	t.Log(stderr.String())
+	return code, stdout.String()
+}
+
+func TestAssert(t *testing.T) {
+	message := "no unused exports allowed in public packages"
+
+	code, out := runArgs(t, assertArg, message, fromArg, "./internal/dummy/v2", toArg, "./testdata/consumer")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "PASS: "+message+"\n", out)
+
+	code, out = runArgs(t, assertArg, message, fromArg, "./internal/dummy/v2", toArg, "./internal/dummy/noexports")
+	assert.Equal(t, unusedExitCode, code)
+	assert.Equal(t, "FAIL: "+message+"\n"+
+		"1 unused exports:\n"+
+		"\tgithub.com/launchdarkly-labs/refaudit/internal/dummy/v2.ExportedFunction\n", out)
+}
+
+func TestScanDocExamples(t *testing.T) {
+	code, out := runArgs(t, scanDocExamplesArg, fromArg, "./testdata/docs", toArg, "./internal/dummy/noexports")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{
+		"github.com/launchdarkly-labs/refaudit/testdata/docs.Commented",
+		"github.com/launchdarkly-labs/refaudit/testdata/docs.Documented",
+		"github.com/launchdarkly-labs/refaudit/testdata/docs.Fenced",
+	}, rpt.DocumentedUsage)
+	assert.Equal(t, []string{"github.com/launchdarkly-labs/refaudit/testdata/docs.Undocumented"}, rpt.UnusedExports)
+	assert.Empty(t, rpt.Imported)
+}
+
+func TestBaselineFormats(t *testing.T) {
+	v1 := "github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedFunction"
+	v2 := "github.com/launchdarkly-labs/refaudit/internal/dummy/v2.ExportedFunction"
+	audit := []string{fromArg, "./internal/dummy/v2", toArg, "./internal/dummy/noexports"}
+
+	code, out := runArgs(t, audit...)
+	require.Equal(t, 0, code)
+	previous := filepath.Join(t.TempDir(), "previous.json")
+	require.NoError(t, os.WriteFile(previous, []byte(out), 0o600))
+
+	for _, tc := range []struct {
+		format, file string
+		expected     BaselineDiff
+	}{
+		{"json", previous, BaselineDiff{NewUnused: []string{}, Resolved: []string{}}},
+		{"csv", "./testdata/baselines/unused.csv", BaselineDiff{NewUnused: []string{}, Resolved: []string{v1}}},
+		{"list", "./testdata/baselines/unused.txt", BaselineDiff{NewUnused: []string{v2}, Resolved: []string{v1}}},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			code, out := runArgs(t, append(audit, baselineArg, tc.file, baselineFormatArg, tc.format)...)
+			require.Equal(t, 0, code)
+			rpt := Report{}
+			require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+			require.NotNil(t, rpt.Baseline)
+			assert.Equal(t, tc.expected, *rpt.Baseline)
+		})
+	}
+
+	code, _ = runArgs(t, append(audit, baselineArg, previous, baselineFormatArg, "yaml")...)
+	assert.Equal(t, 1, code)
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	key := "github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedStruct"
+	imports, err := findImports(context.TODO(), []string{expandPath("./testdata/containers")}, []string{}, nil, moduleFilter{})
+	require.NoError(t, err)
+	assert.Contains(t, imports[key], expandPath("./testdata/containers/store.go"), "composite literal stored in a container")
+	assert.Contains(t, imports[key], expandPath("./testdata/containers/load.go"), "type assertion on a value from a container")
+
+	code, out := runArgs(t, fromArg, "./internal/dummy", toArg, "./testdata/containers")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Imported, key)
+	assert.Contains(t, rpt.Exported, key)
+	assert.NotContains(t, rpt.UnusedExports, key)
+}
+
+func TestDoctor(t *testing.T) {
+	code, out := runArgs(t, doctorCmd)
+	assert.Equal(t, 0, code, out)
+	assert.Contains(t, out, "go version: go")
+	for _, check := range []string{"go toolchain", "load packages", "module resolution", "build constraints"} {
+		assert.Contains(t, out, "[PASS] "+check+"\n")
+	}
+}
+
+func TestFailOnKind(t *testing.T) {
+	audit := []string{fromArg, "./testdata/kinds", excludeFromArg, "./testdata/kinds/consumer", toArg, "./testdata/kinds/consumer"}
+
+	code, _ := runArgs(t, audit...)
+	assert.Equal(t, 0, code, "unused exports don't fail by default")
+	code, _ = runArgs(t, append(audit, failOnKindArg, "func,type")...)
+	assert.Equal(t, 0, code, "only the var is unused")
+	code, _ = runArgs(t, append(audit, failOnKindArg, "func,var")...)
+	assert.Equal(t, unusedExitCode, code)
+	code, _ = runArgs(t, append(audit, failOnKindArg, "func,macro")...)
+	assert.Equal(t, 1, code)
+}
+
+func TestModuleRelative(t *testing.T) {
+	code, out := runArgs(t, moduleRelativeArg, fromArg, "./internal/dummy", toArg, "./testdata/consumer")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Exported, "internal/dummy/v2.ExportedFunction")
+	assert.Contains(t, rpt.Imported, "internal/dummy.Less")
+	assert.Contains(t, rpt.Imported, "sort.Slice", "symbols from other modules keep their full path")
+	assert.Equal(t, 3, rpt.ReferenceCounts["internal/dummy.Counted"])
+	for _, symbol := range append(rpt.Exported, rpt.Imported...) {
+		assert.NotContains(t, symbol, "github.com/launchdarkly-labs/refaudit/")
+	}
+
+	rel := relativizer{[]string{"example.com/lib"}}
+	assert.Equal(t, "Name", rel.symbol("example.com/lib.Name"))
+	assert.Equal(t, "example.com/library/x.Name", rel.symbol("example.com/library/x.Name"))
+	assert.Equal(t, ".", rel.pkgPath("example.com/lib"))
+
+	rel = relativizer{[]string{"gopkg.in/yaml"}}
+	assert.Equal(t, "Marshal", rel.symbol("gopkg.in/yaml.Marshal"))
+	assert.Equal(t, "gopkg.in/yaml.v3.Marshal", rel.symbol("gopkg.in/yaml.v3.Marshal"), "a different module with a dotted prefix")
+}
+
+func TestSink(t *testing.T) {
+	sinkBackoff = 0
+	bodies := [][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		bodies = append(bodies, body)
+		if len(bodies) == 1 {
+			// the first attempt fails and is retried
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	code, out := runArgs(t, fromArg, "./internal/dummy/v2", toArg, "./internal/dummy/noexports", sinkArg, srv.URL, sinkRetriesArg, "1")
+	require.Equal(t, 0, code)
+	require.Len(t, bodies, 2)
+	assert.Equal(t, out, string(bodies[1])+"\n", "the posted report matches the local one")
+
+	rejected := 0
+	rejecting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejected++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer rejecting.Close()
+	code, _ = runArgs(t, fromArg, "./internal/dummy/v2", toArg, "./internal/dummy/noexports", sinkArg, rejecting.URL, sinkRetriesArg, "3")
+	assert.Equal(t, 2, code)
+	assert.Equal(t, 1, rejected, "client errors aren't retried")
+	code, _ = runArgs(t, fromArg, "./internal/dummy/v2", toArg, "./internal/dummy/noexports", sinkArg, srv.URL, sinkTimeoutArg, "soon")
+	assert.Equal(t, 1, code)
+}
+
+func TestSurfaceHash(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/lib\n\ngo 1.17\n",
+		"lib.go": "package lib\n\nfunc A() {}\n\ntype B struct{}\n",
+	})
+	hash := func() string {
+		code, out := runArgs(t, surfaceHashArg, fromArg, dir, toArg, "./internal/dummy/noexports")
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		require.Len(t, rpt.SurfaceHash, 64)
+		return rpt.SurfaceHash
+	}
+
+	initial := hash()
+	assert.Equal(t, initial, hash(), "stable across runs")
+
+	writeFiles(t, dir, map[string]string{"more.go": "package lib\n\nvar C = 1\n"})
+	added := hash()
+	assert.NotEqual(t, initial, added, "an export was added")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "more.go")))
+	assert.Equal(t, initial, hash(), "the export was removed again")
+
+	writeFiles(t, dir, map[string]string{"lib.go": "package lib\n\nfunc A() {}\n\nvar B = struct{}{}\n"})
+	assert.NotEqual(t, initial, hash(), "an export changed kind")
+}
+
+func TestSummaryOnly(t *testing.T) {
+	code, out := runArgs(t, summaryOnlyArg, fromArg, "./testdata/kinds", excludeFromArg, "./testdata/kinds/consumer", toArg, "./testdata/kinds/consumer")
+	require.Equal(t, 0, code)
+	assert.Equal(t, `{"exported":3,"referenced":2,"unused":1}`+"\n", out)
+	assert.NotContains(t, out, "UnusedVar")
+}
+
+func TestSyncOutput(t *testing.T) {
+	combined := &bytes.Buffer{}
+	out := &syncOutput{}
+	stdout, stderr := out.writer(combined), out.writer(combined)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			// progress written in pieces must still come out as whole lines
+			fmt.Fprint(stderr, "progress ")
+			fmt.Fprintf(stderr, "%d\n", i)
+		}
+	}()
+	code := run(context.TODO(), []string{fromArg, "./internal/dummy/v2", toArg, "./internal/dummy/noexports"}, stdout, stderr)
+	<-done
+	require.Equal(t, 0, code)
+	require.NoError(t, stdout.Flush())
+	require.NoError(t, stderr.Flush())
+
+	report := []string{}
+	progress := 0
+	for _, line := range strings.Split(strings.TrimSuffix(combined.String(), "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "progress"):
+			assert.Regexp(t, `^progress \d+$`, line)
+			progress++
+		case strings.HasPrefix(line, "--"):
+			// inputs echoed on stderr
+		default:
+			report = append(report, line)
+		}
+	}
+	assert.Equal(t, 200, progress)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(report, "\n")), &rpt), "the report wasn't interleaved")
+	assert.Equal(t, []string{"github.com/launchdarkly-labs/refaudit/internal/dummy/v2.ExportedFunction"}, rpt.UnusedExports)
+}
+
+func TestTrendFile(t *testing.T) {
+	trend := filepath.Join(t.TempDir(), "trend.ndjson")
+	for i := 0; i < 2; i++ {
+		code, _ := runArgs(t, trendFileArg, trend, fromArg, "./testdata/kinds", excludeFromArg, "./testdata/kinds/consumer", toArg, "./testdata/kinds/consumer")
+		require.Equal(t, 0, code)
+	}
+
+	b, err := os.ReadFile(trend)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	require.Len(t, lines, 2)
+	records := []TrendRecord{}
+	for _, line := range lines {
+		record := TrendRecord{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		assert.Equal(t, Summary{Exported: 3, Referenced: 2, Unused: 1}, record.Summary)
+		records = append(records, record)
+	}
+	assert.True(t, records[1].Time.After(records[0].Time), "timestamps increase")
+	assert.Contains(t, lines[0], `"exported":3,"referenced":2,"unused":1`)
+}
+
+func TestToTags(t *testing.T) {
+	key := "github.com/launchdarkly-labs/refaudit/internal/dummy.ExportedFunction"
+	imported := func(args ...string) []string {
+		code, out := runArgs(t, append([]string{fromArg, "./internal/dummy/v2"}, args...)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt.Imported
+	}
+
+	assert.NotContains(t, imported(toArg, "./testdata/tagged"), key)
+	assert.Contains(t, imported(toArg, "./testdata/tagged", toTagsArg, "integration"), key)
+	assert.NotContains(t, imported(toArg, "./testdata/tagged", toArg, "./internal/dummy/noexports", toTagsArg, "integration"), key,
+		"tags only apply to the last group")
+}
+
+func TestTestOnlyExports(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/testonly"
+	code, out := runArgs(t, testOnlyExportsArg, fromArg, "./testdata/testonly", excludeFromArg, "./testdata/testonly/consumer", toArg, "./testdata/testonly/consumer")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{pkg + ".ExternallyTested", pkg + ".TestedOnly"}, rpt.TestOnlyExports)
+	assert.Equal(t, []string{pkg + ".ExternallyTested", pkg + ".TestedOnly"}, rpt.UnusedExports)
+}
+
+func TestSortByLocation(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/location"
+	unused := func(args ...string) []string {
+		code, out := runArgs(t, append([]string{fromArg, "./testdata/location", toArg, "./internal/dummy/noexports"}, args...)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt.UnusedExports
+	}
+
+	assert.Equal(t, []string{pkg + ".A", pkg + ".Alpha", pkg + ".B", pkg + ".Zed"}, unused())
+	assert.Equal(t, []string{pkg + ".Zed", pkg + ".A", pkg + ".B", pkg + ".Alpha"}, unused(sortArg, "location"))
+}
+
+func TestExcludeModule(t *testing.T) {
+	code, out := runArgs(t, fromArg, "./testdata/modules", toArg, "./testdata/modules", excludeModuleArg, "example.com/two")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{"example.com/one.One"}, rpt.Exported)
+	assert.Equal(t, []string{"fmt.Println"}, rpt.Imported)
+
+	// --precise loads each module from its own directory
+	code, out = runArgs(t, fromArg, "./testdata/modules", toArg, "./testdata/modules/one", "./testdata/modules/two", excludeModuleArg, "example.com/two", preciseArg)
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{"fmt.Println"}, rpt.Imported)
+}
+
+func TestVerify(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/verify/lib"
+	audit := []string{fromArg, "./testdata/verify/lib", toArg, "./testdata/verify/consumer"}
+	report := func(args ...string) Report {
+		code, out := runArgs(t, append(audit, args...)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt
+	}
+
+	rpt := report()
+	assert.Equal(t, []string{pkg + ".Dead", pkg + ".Dynamic", pkg + ".Plugin", pkg + ".Plugin.Dynamic"}, rpt.UnusedExports)
+	assert.Empty(t, rpt.NeedsReview)
+
+	rpt = report(verifyArg)
+	assert.Equal(t, []string{pkg + ".Dead", pkg + ".Plugin"}, rpt.UnusedExports)
+	assert.Equal(t, []string{pkg + ".Dynamic", pkg + ".Plugin.Dynamic"}, rpt.NeedsReview)
+}
+
+func TestSimulateRemove(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/simulate/lib"
+	code, out := runArgs(t,
+		fromArg, "./testdata/simulate/lib",
+		toArg, "./testdata/simulate/billing", "./testdata/simulate/web",
+		simulateRemoveArg, "./testdata/simulate/billing",
+	)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Empty(t, rpt.UnusedExports)
+	require.NotNil(t, rpt.SimulatedRemoval)
+	assert.Equal(t, []string{pkg + ".Solo"}, rpt.SimulatedRemoval.NewlyUnused)
+}
+
+func TestGroupByModule(t *testing.T) {
+	code, out := runArgs(t, fromArg, "./testdata/modules", toArg, "./testdata/modules/one", groupByArg, "module")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []ModuleReport{
+		{
+			Module:        "example.com/one",
+			Exported:      []string{"example.com/one.One"},
+			UnusedExports: []string{"example.com/one.One"},
+			Counts:        Summary{Exported: 1, Unused: 1},
+		},
+		{
+			Module:        "example.com/two",
+			Exported:      []string{"example.com/two.Two"},
+			UnusedExports: []string{"example.com/two.Two"},
+			Counts:        Summary{Exported: 1, Unused: 1},
+		},
+	}, rpt.Modules)
+}
+
+func TestExitCodeCount(t *testing.T) {
+	code, _ := runArgs(t, fromArg, "./testdata/verify/lib", toArg, "./testdata/verify/consumer", exitCodeCountArg)
+	assert.Equal(t, countExitCodeBase+4, code)
+	code, _ = runArgs(t, fromArg, "./testdata/simulate/lib", toArg, "./testdata/simulate/billing", exitCodeCountArg)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, 11, countExitCode(1), "clear of the usage and internal error codes")
+	assert.Equal(t, maxExitCode, countExitCode(1000))
+}
+
+func TestSingleConsumer(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/simulate"
+	code, out := runArgs(t,
+		fromArg, "./testdata/simulate/lib",
+		toArg, "./testdata/simulate/billing", "./testdata/simulate/web",
+		singleConsumerArg,
+	)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []SingleConsumer{{Export: pkg + "/lib.Solo", Consumer: pkg + "/billing"}}, rpt.SingleConsumerExports)
+}
+
+func TestFacade(t *testing.T) {
+	impl := "github.com/launchdarkly-labs/refaudit/testdata/facade/internal/impl"
+	audit := []string{fromArg, "./testdata/facade/internal", toArg, "./testdata/facade/consumer"}
+	report := func(args ...string) Report {
+		code, out := runArgs(t, append(audit, args...)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt
+	}
+
+	assert.Equal(t, []string{impl + ".Do", impl + ".Unwrapped"}, report().UnusedExports)
+	assert.Equal(t, []string{impl + ".Unwrapped"}, report(facadeArg, "./testdata/facade/api").UnusedExports)
+}
+
+func TestFields(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/fields/lib"
+	audit := []string{fromArg, "./testdata/fields/lib", toArg, "./testdata/fields/consumer"}
+	report := func(args ...string) Report {
+		code, out := runArgs(t, append(audit, args...)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt
+	}
+
+	rpt := report()
+	assert.Equal(t, []string{pkg + ".Config"}, rpt.Exported)
+	assert.Empty(t, rpt.UnusedExports)
+
+	rpt = report(fieldsArg)
+	assert.Equal(t, []string{
+		pkg + ".Config",
+		pkg + ".Config.Host",
+		pkg + ".Config.Name",
+		pkg + ".Config.Port",
+		pkg + ".Config.Retries",
+		pkg + ".Config.Timeout",
+	}, rpt.Exported)
+	// Name is selected from a literal, Timeout from a parameter, and Host and
+	// Port from variables
+	assert.Equal(t, []string{pkg + ".Config.Retries"}, rpt.UnusedExports)
+}
+
+func TestAPISpec(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/simulate/lib"
+	audit := []string{fromArg, "./testdata/simulate/lib", toArg, "./testdata/simulate/billing", apiSpecArg, "./testdata/spec/api.txt"}
+	code, out := runArgs(t, audit...)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, &SpecDiff{
+		Undocumented: []string{pkg + ".Solo"},
+		Missing:      []string{pkg + ".Renamed"},
+	}, rpt.APISpec)
+
+	code, _ = runArgs(t, append(audit, enforceSpecArg)...)
+	assert.Equal(t, specExitCode, code)
+}
+
+func TestArchives(t *testing.T) {
+	report := func(args ...string) Report {
+		code, out := runArgs(t, args...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt
+	}
+
+	unpacked := report(fromArg, "./testdata/archive/src", toArg, "./testdata/archive/src")
+	archived := report(fromArchiveArg, "./testdata/archive/src.zip", toArchiveArg, "./testdata/archive/src.zip")
+	assert.Equal(t, []string{"example.com/archived/lib.Unused"}, unpacked.UnusedExports)
+	assert.Equal(t, unpacked.Exported, archived.Exported)
+	assert.Equal(t, unpacked.Imported, archived.Imported)
+	assert.Equal(t, unpacked.UnusedExports, archived.UnusedExports)
+}
+
+func TestPositions(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/location"
+	code, out := runArgs(t, fromArg, "./testdata/location", toArg, "./internal/dummy/noexports", positionsArg)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	require.Len(t, rpt.Positions, len(rpt.UnusedExports))
+	file := filepath.Join(expandPath("./testdata/location"), "a.go")
+	assert.Equal(t, Position{file, 4}, rpt.Positions[pkg+".Zed"])
+	assert.Equal(t, Position{file, 6}, rpt.Positions[pkg+".A"])
+}
+
+func TestFormat(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/location"
+	dir := expandPath("./testdata/location")
+	audit := []string{fromArg, "./testdata/location", toArg, "./internal/dummy/noexports", formatArg}
+
+	code, out := runArgs(t, append(audit, "text")...)
+	require.Equal(t, 0, code)
+	assert.Equal(t, pkg+".A\n"+pkg+".Alpha\n"+pkg+".B\n"+pkg+".Zed\n", out)
+
+	code, out = runArgs(t, append(append(audit, "list"), sortArg, "location")...)
+	require.Equal(t, 0, code)
+	assert.Equal(t, pkg+".A\n"+pkg+".Alpha\n"+pkg+".B\n"+pkg+".Zed\n", out, "always sorted by symbol")
+
+	code, out = runArgs(t, append(audit, "csv")...)
+	require.Equal(t, 0, code)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	require.Len(t, lines, 5)
+	assert.Equal(t, "symbol,kind,file,line", lines[0])
+	assert.Equal(t, pkg+".Zed,func,"+filepath.Join(dir, "a.go")+",4", lines[4])
+
+	code, _ = runArgs(t, append(audit, "yaml")...)
+	assert.Equal(t, 1, code)
+}
+
+func TestFailOnUnused(t *testing.T) {
+	code, _ := runArgs(t, fromArg, "./testdata/simulate/lib", toArg, "./testdata/simulate/web", failOnUnusedArg)
+	assert.Equal(t, unusedExitCode, code)
+	code, _ = runArgs(t, fromArg, "./testdata/simulate/lib", toArg, "./testdata/simulate/billing", failOnUnusedArg)
+	assert.Equal(t, 0, code)
+	code, _ = runArgs(t, fromArg, "./testdata/simulate/lib", toArg, "./testdata/simulate/web")
+	assert.Equal(t, 0, code, "unused exports don't fail by default")
+}
+
+func TestToDeps(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":          "module example.com/app\n\ngo 1.17\n",
+		"lib/lib.go":      "package lib\n\nfunc Deep() {}\n\nfunc Unused() {}\n",
+		"b/b.go":          "package b\n\nimport \"example.com/app/lib\"\n\nfunc B() { lib.Deep() }\n",
+		"a/a.go":          "package a\n\nimport \"example.com/app/b\"\n\nfunc A() { b.B() }\n",
+		"cmd/app/main.go": "package main\n\nimport \"example.com/app/a\"\n\nfunc main() { a.A() }\n",
+		"other/other.go":  "package other\n\nimport \"example.com/app/lib\"\n\nfunc Other() { lib.Unused() }\n",
+	})
+
+	code, out := runArgs(t, fromArg, filepath.Join(dir, "lib"), toDepsArg, filepath.Join(dir, "cmd", "app"))
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Imported, "example.com/app/lib.Deep", "reached through a and b")
+	assert.Equal(t, []string{"example.com/app/lib.Unused"}, rpt.UnusedExports, "other isn't a dependency")
+}
+
+func TestPackagesJSON(t *testing.T) {
+	dummy := "github.com/launchdarkly-labs/refaudit/internal/dummy."
+	code, out := runArgs(t, fromArg, "./internal/dummy", packagesJSONArg, "./testdata/packagesjson/packages.json")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Imported, dummy+"Counted")
+	assert.NotContains(t, rpt.Imported, dummy+"Compute", "return.go isn't listed")
+	assert.Contains(t, rpt.UnusedExports, dummy+"Compute")
+
+	code, _ = runArgs(t, fromArg, "./internal/dummy", packagesJSONArg, "./testdata/packagesjson/missing.json")
+	assert.Equal(t, 1, code)
+}
+
+func TestExcludeGlobArg(t *testing.T) {
+	code, out := runArgs(t, fromArg, "./testdata/generated", toArg, "./testdata/generated", excludeGlobArg, "mock_*.go")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	for _, symbol := range rpt.Exported {
+		assert.NotContains(t, symbol, "/mocks.")
+	}
+
+	code, _ = runArgs(t, fromArg, "./testdata/generated", excludeGlobArg, "[")
+	assert.Equal(t, 1, code)
+}
+
+func TestInterfaceGranularity(t *testing.T) {
+	store := "github.com/launchdarkly-labs/refaudit/internal/dummy.Store"
+	audit := []string{fromArg, "./internal/dummy", toArg, "./testdata/consumer", preciseArg}
+	code, out := runArgs(t, audit...)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Exported, store)
+	assert.NotContains(t, rpt.Exported, store+".Get", "type is the default")
+	assert.NotContains(t, rpt.Exported, store+".Put")
+
+	code, out = runArgs(t, append(audit, interfaceGranularityArg, "method")...)
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Exported, store+".Get")
+	assert.NotContains(t, rpt.UnusedExports, store+".Get", "called through the interface")
+	assert.Contains(t, rpt.UnusedExports, store+".Put")
+
+	code, _ = runArgs(t, append(audit, interfaceGranularityArg, "package")...)
+	assert.Equal(t, 1, code)
+
+	code, out = runArgs(t, fromArg, "./internal/dummy", toArg, "./testdata/consumer", interfaceGranularityArg, "method")
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.NotContains(t, rpt.UnusedExports, store+".Get", "a call by the same name")
+	assert.NotContains(t, rpt.UnusedExports, "github.com/launchdarkly-labs/refaudit/internal/dummy.Reader.Read")
+	assert.Contains(t, rpt.UnusedExports, store+".Put")
+}
+
+func TestStdin(t *testing.T) {
+	defer func() { stdin = os.Stdin }()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":     "module example.com/app\n\ngo 1.17\n",
+		"app/app.go": "package app\n\nimport \"example.com/lib\"\n\nfunc App() { lib.Used() }\n",
+	})
+
+	stdin = strings.NewReader("package lib\n\nfunc Used() {}\n\nfunc Unused() {}\n")
+	code, out := runArgs(t, fromArg, stdinArg, stdinPathArg, "example.com/lib", toArg, dir)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{"example.com/lib.Unused", "example.com/lib.Used"}, rpt.Exported)
+	assert.Equal(t, []string{"example.com/lib.Unused"}, rpt.UnusedExports)
+
+	stdin = strings.NewReader("package app\n\nimport \"github.com/launchdarkly-labs/refaudit/internal/dummy\"\n\nvar n = dummy.Counted()\n")
+	code, out = runArgs(t, fromArg, "./internal/dummy", toArg, stdinArg)
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{"github.com/launchdarkly-labs/refaudit/internal/dummy.Counted"}, rpt.Imported)
+
+	stdin = strings.NewReader("package lib\n")
+	code, _ = runArgs(t, fromArg, stdinArg, toArg, dir)
+	assert.Equal(t, 1, code, "the import path is needed")
+	stdin = strings.NewReader("package lib\n\nfunc {\n")
+	code, _ = runArgs(t, toArg, stdinArg)
+	assert.Equal(t, 1, code, "bad source")
+}
+
+func TestRefRegex(t *testing.T) {
+	lib := "github.com/launchdarkly-labs/refaudit/testdata/routes/lib."
+	audit := []string{fromArg, "./testdata/routes/lib", toArg, "./testdata/routes/server"}
+	code, out := runArgs(t, append(audit, refRegexArg, `^/api/(\w+\.\w+)$`)...)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{lib + "Service.GetUser"}, rpt.PossiblyReflected)
+	assert.Equal(t, []string{lib + "Service.DeleteUser"}, rpt.UnusedExports)
+
+	code, _ = runArgs(t, append(audit, refRegexArg, `^/api/\w+$`)...)
+	assert.Equal(t, 1, code, "no group")
+	code, _ = runArgs(t, append(audit, refRegexArg, `(`)...)
+	assert.Equal(t, 1, code)
+}
+
+func TestRequireConsumerGroup(t *testing.T) {
+	lib := "github.com/launchdarkly-labs/refaudit/testdata/groups/lib."
+	audit := []string{fromArg, "./testdata/groups/lib", toArg, "./testdata/groups/approved", toGroupArg, "approved", toArg, "./testdata/groups/other"}
+	code, out := runArgs(t, append(audit, requireConsumerGroupArg, "approved")...)
+	assert.Equal(t, orphanExitCode, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{lib + "Experimental", lib + "Orphan"}, rpt.ConsumerGroupOrphans)
+	assert.Equal(t, []string{lib + "Orphan"}, rpt.UnusedExports)
+
+	code, out = runArgs(t, fromArg, "./testdata/groups/lib", toArg, "./testdata/groups/approved", "./testdata/groups/other", toGroupArg, "all", requireConsumerGroupArg, "all")
+	assert.Equal(t, orphanExitCode, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{lib + "Orphan"}, rpt.ConsumerGroupOrphans, "a group of several directories")
+	code, _ = runArgs(t, append(audit, requireConsumerGroupArg, "missing")...)
+	assert.Equal(t, 1, code)
+}
+
+func TestPreciseShadowedPackage(t *testing.T) {
+	lib := "github.com/launchdarkly-labs/refaudit/testdata/shadow/lib."
+	audit := []string{fromArg, "./testdata/shadow/lib", toArg, "./testdata/shadow/app"}
+	code, out := runArgs(t, audit...)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.Imported, lib+"Name", "the local variable is mistaken for the package")
+	assert.Equal(t, []string{lib + "Config"}, rpt.UnusedExports, "app never names it")
+
+	code, out = runArgs(t, append(audit, preciseArg)...)
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.NotContains(t, rpt.Imported, lib+"Name")
+	assert.Contains(t, rpt.Imported, lib+"Load")
+	assert.Equal(t, []string{lib + "Config", lib + "Name"}, rpt.UnusedExports)
+}
+
+func TestPreciseLoadDiagnostics(t *testing.T) {
+	audit := []string{fromArg, "./internal/dummy", toArg, "./testdata/missingimport"}
+	code, out := runArgs(t, append(audit, packagesErrorsArg)...)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Empty(t, rpt.LoadDiagnostics, "consumers aren't loaded without --precise")
+
+	code, out = runArgs(t, append(audit, preciseArg, packagesErrorsArg)...)
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	require.NotEmpty(t, rpt.LoadDiagnostics)
+	assert.Contains(t, strings.Join(rpt.LoadDiagnostics, "\n"), "github.com/launchdarkly-labs/refaudit/testdata/missingimport: ")
+	assert.Contains(t, strings.Join(rpt.LoadDiagnostics, "\n"), "internal/missing")
+	assert.Contains(t, rpt.Imported, "github.com/launchdarkly-labs/refaudit/internal/dummy.Start", "what resolved is still credited")
+
+	code, _ = runArgs(t, append(audit, preciseArg, strictArg)...)
+	assert.Equal(t, 2, code)
+}
+
+func TestTestExports(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/testvariants"
+	audit := []string{fromArg, "./testdata/testvariants", toArg, "./internal/dummy/noexports"}
+	for _, flag := range []string{testExportsArg, includeTestsArg} {
+		code, out := runArgs(t, append(audit, flag)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		assert.Contains(t, rpt.Exported, pkg+"_test.ExternalHelper", flag)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "v1.2.3", "abc123", "2024-01-02T03:04:05Z"
+	code, out := runArgs(t, fromArg, "./does/not/exist", formatArg, "yaml", versionArg)
+	assert.Equal(t, 0, code, "no other argument is looked at")
+	assert.Equal(t, "refaudit v1.2.3\ncommit: abc123\nbuilt: 2024-01-02T03:04:05Z\n", out)
+
+	version, commit, date = "", "", ""
+	code, out = runArgs(t, versionArg)
+	assert.Equal(t, 0, code)
+	assert.Contains(t, out, "commit: unknown\n")
+}
+
+func TestListPackages(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/generated"
+	code, out := runArgs(t, fromArg, "./testdata/generated", toArg, "./testdata/generated/mocks", listPackagesArg)
+	require.Equal(t, 0, code)
+	assert.Equal(t, pkg+"\t2\n"+pkg+"/mocks\t1\n", out)
+}
+
+func TestRefManifest(t *testing.T) {
+	lib := "github.com/launchdarkly-labs/refaudit/testdata/manifest/lib."
+	code, out := runArgs(t, fromArg, "./testdata/manifest/lib", toArg, "./testdata/manifest/lib", refManifestArg, "./testdata/manifest/plugins.yaml", manifestKeyArg, "plugins.*.handler")
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{lib + "Handler", lib + "Plugin.Serve"}, rpt.ManifestUsage)
+	assert.Equal(t, []string{lib + "Plugin", lib + "Unlisted"}, rpt.UnusedExports)
+
+	code, out = runArgs(t, fromArg, "./testdata/manifest/lib", toArg, "./testdata/manifest/lib", refManifestArg, "./testdata/manifest/plugins.yaml")
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Contains(t, rpt.ManifestUsage, lib+"Unlisted", "every string counts without a key")
+
+	exports := map[string]interface{}{"gopkg.in/yaml.v3.Marshal": exists, "example.com/foo.bar.Type.Method": exists}
+	pkgs := map[string]interface{}{"gopkg.in/yaml.v3": exists, "example.com/foo.bar": exists}
+	names := map[string]interface{}{"Marshal": exists, "Type.Method": exists}
+	assert.Equal(t, exports, manifestUsage(exports, pkgs, names), "packages with a dot in their last element")
+}
+
+func TestBuildTags(t *testing.T) {
+	if runtime.GOOS == "plan9" {
+		t.Skip("the fixture's other platform is plan9")
+	}
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/constraints."
+	lib, use := "./testdata/constraints", "./testdata/constraints/use"
+
+	code, out := runArgs(t, fromArg, lib, excludeFromArg, use, toArg, use)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{pkg + "Common"}, rpt.Exported, "other platforms and tags are skipped")
+	assert.Empty(t, rpt.Imported)
+
+	code, out = runArgs(t, fromArg, lib, excludeFromArg, use, toArg, use, buildTagsArg, "refaudit_extra")
+	require.Equal(t, 0, code)
+	rpt = Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []string{pkg + "Common", pkg + "Extra"}, rpt.Exported)
+	assert.Equal(t, []string{pkg + "Common"}, rpt.Imported)
+	assert.Equal(t, []string{pkg + "Extra"}, rpt.UnusedExports)
+}
+
+func TestTestRefs(t *testing.T) {
+	pkg := "github.com/launchdarkly-labs/refaudit/testdata/testrefs."
+	audit := func(mode string) Report {
+		code, out := runArgs(t, fromArg, "./testdata/testrefs", excludeFromArg, "./testdata/testrefs/use", toArg, "./testdata/testrefs/use", testRefsArg, mode)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		return rpt
+	}
+
+	rpt := audit("include")
+	assert.Empty(t, rpt.UnusedExports)
+	assert.Empty(t, rpt.TestOnlyUsage)
+
+	rpt = audit("exclude")
+	assert.Equal(t, []string{pkg + "TestedOnly"}, rpt.UnusedExports)
+	assert.Empty(t, rpt.TestOnlyUsage)
+
+	rpt = audit("separate")
+	assert.Equal(t, []string{pkg + "TestedOnly"}, rpt.UnusedExports)
+	assert.Equal(t, []string{pkg + "TestedOnly"}, rpt.TestOnlyUsage)
+
+	code, _ := runArgs(t, fromArg, "./testdata/testrefs", toArg, "./testdata/testrefs/use", testRefsArg, "sometimes")
+	assert.Equal(t, 1, code)
+}
+
+func TestBaselineMovedExport(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod": "module example.com/lib\n\ngo 1.17\n",
+		"a.go":   "package lib\n\nfunc Moved() {}\n",
+	})
+	code, out := runArgs(t, fromArg, dir, toArg, dir, positionsArg)
+	require.Equal(t, 0, code)
+	previous := filepath.Join(t.TempDir(), "previous.json")
+	require.NoError(t, os.WriteFile(previous, []byte(out), 0o600))
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "a.go")))
+	writeFiles(t, dir, map[string]string{"b.go": "package lib\n\nfunc Moved() {}\n"})
+	diff := func(args ...string) BaselineDiff {
+		code, out := runArgs(t, append([]string{fromArg, dir, toArg, dir, baselineArg, previous}, args...)...)
+		require.Equal(t, 0, code)
+		rpt := Report{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		require.NotNil(t, rpt.Baseline)
+		return *rpt.Baseline
+	}
+
+	assert.Equal(t, BaselineDiff{NewUnused: []string{}, Resolved: []string{}}, diff(), "moving isn't a regression")
+	moved := []string{"example.com/lib.Moved"}
+	assert.Equal(t, BaselineDiff{NewUnused: moved, Resolved: moved}, diff(baselinePositionsArg))
+}
+
+func TestConflictingExports(t *testing.T) {
+	a, b := expandPath("./testdata/conflict/a"), expandPath("./testdata/conflict/b")
+	code, out := runArgs(t, fromArg, a, fromArg, b, toArg, a)
+	require.Equal(t, 0, code)
+	rpt := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []Conflict{{
+		Export: "example.com/dup.Shared",
+		Declarations: []Position{
+			{filepath.Join(a, "dup.go"), 4},
+			{filepath.Join(b, "dup.go"), 4},
+		},
+	}}, rpt.ConflictingExports)
+}
+
+func TestSortedInsert(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		list     []string
+		elem     string
+		expected []string
+	}{
+		{"empty", nil, "b", []string{"b"}},
+		{"front", []string{"b", "d"}, "a", []string{"a", "b", "d"}},
+		{"middle", []string{"b", "d"}, "c", []string{"b", "c", "d"}},
+		{"end", []string{"b", "d"}, "e", []string{"b", "d", "e"}},
+		{"duplicate at the front", []string{"b", "d"}, "b", []string{"b", "d"}},
+		{"duplicate at the end", []string{"b", "d"}, "d", []string{"b", "d"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, sortedInsert(tc.list, tc.elem))
+		})
+	}
+
+	// spare capacity must not let an insert clobber what comes after
+	list := make([]string, 0, 8)
+	for _, elem := range []string{"d", "b", "c", "a", "c", "e"} {
+		list = sortedInsert(list, elem)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, list)
+}
+
+func TestPathLists(t *testing.T) {
+	lines, err := readPathList([]byte("# comment\n\n  a  \nb\n#c\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, lines)
+
+	inline, inlineOut := runArgs(t, fromArg, "./internal/dummy/v2", toArg, "./testdata/consumer")
+	require.Equal(t, 0, inline)
+	listed, listedOut := runArgs(t, fromArg, "@testdata/pathlists/from.txt", toArg, "@testdata/pathlists/to.txt")
+	require.Equal(t, 0, listed)
+	assert.JSONEq(t, inlineOut, listedOut)
+
+	code, _ := runArgs(t, fromArg, "@testdata/pathlists/missing.txt", toArg, "./testdata/consumer")
+	assert.Equal(t, 1, code)
+}
+
+func TestWithUsages(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":      "module example.com/lib\n\ngo 1.17\n",
+		"lib.go":      "package lib\n\nfunc Shared() {}\n\nfunc Once() {}\n",
+		"a/a.go":      "package a\n\nimport \"example.com/lib\"\n\nfunc A() {\n\tlib.Shared()\n\tlib.Once()\n}\n",
+		"b/b.go":      "package b\n\nimport \"example.com/lib\"\n\nfunc B() { lib.Shared() }\n",
+		"b/b_test.go": "package b\n\nimport \"example.com/lib\"\n\nfunc helper() { lib.Shared() }\n",
+	})
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+
+	code, out := runArgs(t, fromArg, dir, excludeFromArg, a, excludeFromArg, b, toArg, a, toArg, b, withUsagesArg)
+	require.Equal(t, 0, code)
+	var rpt struct {
+		Imported      []ImportedUsage
+		UnusedExports []string
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+	assert.Equal(t, []ImportedUsage{
+		{"example.com/lib.Once", []string{filepath.Join(a, "a.go")}},
+		{"example.com/lib.Shared", []string{filepath.Join(a, "a.go"), filepath.Join(b, "b.go"), filepath.Join(b, "b_test.go")}},
+	}, rpt.Imported)
+	assert.Empty(t, rpt.UnusedExports)
+
+	code, out = runArgs(t, fromArg, dir, excludeFromArg, a, excludeFromArg, b, toArg, a, toArg, b)
+	require.Equal(t, 0, code)
+	plain := Report{}
+	require.NoError(t, json.Unmarshal([]byte(out), &plain))
+	assert.Equal(t, []string{"example.com/lib.Once", "example.com/lib.Shared"}, plain.Imported, "plain list without the flag")
+}
+
+func TestParseArgs(t *testing.T) {
+	cfg, err := parseArgs([]string{fromArg, "./lib", toArg, "./a", toGroupArg, "web", toArg, "./b", formatArg, "csv"}, io.Discard)
+	require.NoError(t, err)
+	require.Len(t, cfg.to, 2)
+	assert.Equal(t, map[string][]string{"web": cfg.to[:1]}, cfg.consumerGroups)
+	assert.Equal(t, "csv", cfg.format)
+	assert.NoError(t, cfg.validate())
+
+	cfg, err = parseArgs([]string{fromArg, "./lib", toArg, "./a", sortArg, "size"}, io.Discard)
+	require.NoError(t, err)
+	assert.EqualError(t, cfg.validate(), "unsupported "+sortArg+" value: size")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestNames reads the strings at key in each YAML or JSON manifest. key is
+// a dot separated path, optionally starting with "$.", in which "*" matches
+// any map key or list element, like "plugins.*.handler". An empty key matches
+// every string in the manifest.
+func manifestNames(files []string, key string) (map[string]interface{}, error) {
+	path := []string{}
+	if key = strings.TrimPrefix(strings.TrimPrefix(key, "$"), "."); key != "" {
+		path = strings.Split(key, ".")
+	}
+
+	names := make(map[string]interface{})
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("could not read manifest %s: %w", file, err)
+		}
+		// YAML is a superset of JSON, so this reads both
+		var doc interface{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return nil, fmt.Errorf("could not parse manifest %s: %w", file, err)
+		}
+		collectStrings(doc, path, len(path) == 0, names)
+	}
+	return names, nil
+}
+
+// collectStrings adds the strings in node at path to names. If deep is set,
+// every string below path is added, not just the ones right at it.
+func collectStrings(node interface{}, path []string, deep bool, names map[string]interface{}) {
+	if len(path) == 0 {
+		switch n := node.(type) {
+		case string:
+			names[n] = exists
+		case map[string]interface{}:
+			if deep {
+				for _, child := range n {
+					collectStrings(child, nil, deep, names)
+				}
+			}
+		case []interface{}:
+			// a list of names at the path
+			for _, child := range n {
+				collectStrings(child, nil, deep, names)
+			}
+		}
+		return
+	}
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for k, child := range n {
+			if path[0] == "*" || path[0] == k {
+				collectStrings(child, path[1:], deep, names)
+			}
+		}
+	case []interface{}:
+		if path[0] == "*" {
+			for _, child := range n {
+				collectStrings(child, path[1:], deep, names)
+			}
+		}
+	}
+}
+
+// manifestUsage returns the exports named in a manifest, either fully
+// qualified or by their name in their package, like "Func" or "Type.Method".
+// pkgPaths are the packages the exports are declared in.
+func manifestUsage(exports map[string]interface{}, pkgPaths map[string]interface{}, names map[string]interface{}) map[string]interface{} {
+	used := make(map[string]interface{})
+	for symbol := range exports {
+		// package paths can have dots in their last element, like
+		// gopkg.in/yaml.v3
+		local := strings.TrimPrefix(symbol, declaringPackage(symbol, pkgPaths)+".")
+		_, full := names[symbol]
+		_, short := names[local]
+		if full || short {
+			used[symbol] = exists
+		}
+	}
+	return used
+}
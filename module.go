@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// modulePath returns the path of the module dir is in, from its go.mod.
+func modulePath(dir string) (string, error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		f, err := os.Open(filepath.Join(d, "go.mod"))
+		if os.IsNotExist(err) {
+			if filepath.Dir(d) == d {
+				return "", fmt.Errorf("%s is not in a go module", dir)
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "module" {
+				if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+					return unquoted, nil
+				}
+				return fields[1], nil
+			}
+		}
+		return "", fmt.Errorf("no module directive in %s", f.Name())
+	}
+}
+
+// relativizer shortens symbols and package paths from the audited modules to
+// be relative to their module.
+type relativizer struct {
+	// longest first, so nested modules win
+	modules []string
+}
+
+// newRelativizer finds the modules the dirs are in.
+func newRelativizer(dirs []string) (relativizer, error) {
+	found := make(map[string]interface{})
+	for _, dir := range dirs {
+		mod, err := modulePath(dir)
+		if err != nil {
+			return relativizer{}, err
+		}
+		found[mod] = exists
+	}
+	modules := []string{}
+	for mod := range found {
+		modules = append(modules, mod)
+	}
+	sort.Slice(modules, func(i, j int) bool { return len(modules[i]) > len(modules[j]) })
+	return relativizer{modules}, nil
+}
+
+// pkgPath returns the path of pkgPath relative to its module, or "." for the
+// module's root package. Other packages are left alone.
+func (r relativizer) pkgPath(pkgPath string) string {
+	for _, mod := range r.modules {
+		if pkgPath == mod {
+			return "."
+		}
+		if strings.HasPrefix(pkgPath, mod+"/") {
+			return pkgPath[len(mod)+1:]
+		}
+	}
+	return pkgPath
+}
+
+// symbol returns symbol with its package path relative to its module. Symbols
+// in the module's root package are left with just their name.
+func (r relativizer) symbol(symbol string) string {
+	for _, mod := range r.modules {
+		// gopkg.in/yaml.v3.Marshal shares the prefix of the module
+		// gopkg.in/yaml, but v3 isn't a name it declares
+		if rest := strings.TrimPrefix(symbol, mod+"."); rest != symbol && isSymbolName(rest) {
+			return rest
+		}
+		if strings.HasPrefix(symbol, mod+"/") {
+			return symbol[len(mod)+1:]
+		}
+	}
+	return symbol
+}
+
+// isSymbolName reports whether name is the name of an export in its package,
+// like Name, Type.Method or Type.Field.
+func isSymbolName(name string) bool {
+	for _, elem := range strings.Split(name, ".") {
+		if !token.IsIdentifier(elem) || !token.IsExported(elem) {
+			return false
+		}
+	}
+	return true
+}
+
+// symbols shortens a list of symbols, keeping their order.
+func (r relativizer) symbols(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	short := make([]string, 0, len(list))
+	for _, symbol := range list {
+		short = append(short, r.symbol(symbol))
+	}
+	return short
+}
+
+// report shortens every symbol and package path in rpt.
+func (r relativizer) report(rpt *Report) {
+	rpt.Exported = r.symbols(rpt.Exported)
+	rpt.Imported = r.symbols(rpt.Imported)
+	rpt.UnusedExports = r.symbols(rpt.UnusedExports)
+	rpt.InterfaceUsed = r.symbols(rpt.InterfaceUsed)
+	rpt.DocumentedUsage = r.symbols(rpt.DocumentedUsage)
+	rpt.TestOnlyExports = r.symbols(rpt.TestOnlyExports)
+	rpt.TestOnlyUsage = r.symbols(rpt.TestOnlyUsage)
+	rpt.ManifestUsage = r.symbols(rpt.ManifestUsage)
+	rpt.PossiblyReflected = r.symbols(rpt.PossiblyReflected)
+	rpt.ConsumerGroupOrphans = r.symbols(rpt.ConsumerGroupOrphans)
+	rpt.NeedsReview = r.symbols(rpt.NeedsReview)
+	rpt.GeneratedOnlyUsage = r.symbols(rpt.GeneratedOnlyUsage)
+	if rpt.ReferenceCounts != nil {
+		counts := make(map[string]int, len(rpt.ReferenceCounts))
+		for symbol, n := range rpt.ReferenceCounts {
+			counts[r.symbol(symbol)] = n
+		}
+		rpt.ReferenceCounts = counts
+	}
+	if rpt.ReferencedBy != nil {
+		by := make(map[string][]string, len(rpt.ReferencedBy))
+		for symbol, consumers := range rpt.ReferencedBy {
+			short := make([]string, 0, len(consumers))
+			for _, consumer := range consumers {
+				short = append(short, r.pkgPath(consumer))
+			}
+			by[r.symbol(symbol)] = short
+		}
+		rpt.ReferencedBy = by
+	}
+	if rpt.Positions != nil {
+		positions := make(map[string]Position, len(rpt.Positions))
+		for symbol, pos := range rpt.Positions {
+			positions[r.symbol(symbol)] = pos
+		}
+		rpt.Positions = positions
+	}
+	if rpt.LastModified != nil {
+		dates := make(map[string]string, len(rpt.LastModified))
+		for symbol, date := range rpt.LastModified {
+			dates[r.symbol(symbol)] = date
+		}
+		rpt.LastModified = dates
+	}
+	for i, pkgPath := range rpt.BlankImports {
+		rpt.BlankImports[i] = r.pkgPath(pkgPath)
+	}
+	for i := range rpt.ConsumerCoupling {
+		rpt.ConsumerCoupling[i].Consumer = r.pkgPath(rpt.ConsumerCoupling[i].Consumer)
+	}
+	for i := range rpt.ConflictingExports {
+		rpt.ConflictingExports[i].Export = r.symbol(rpt.ConflictingExports[i].Export)
+	}
+	for i := range rpt.SingleConsumerExports {
+		single := &rpt.SingleConsumerExports[i]
+		single.Export = r.symbol(single.Export)
+		single.Consumer = r.pkgPath(single.Consumer)
+	}
+	for i := range rpt.Packages {
+		pkg := &rpt.Packages[i]
+		pkg.Package = r.pkgPath(pkg.Package)
+		pkg.Exported = r.symbols(pkg.Exported)
+		pkg.UnusedExports = r.symbols(pkg.UnusedExports)
+	}
+	for i := range rpt.Modules {
+		mod := &rpt.Modules[i]
+		mod.Exported = r.symbols(mod.Exported)
+		mod.UnusedExports = r.symbols(mod.UnusedExports)
+	}
+	if rpt.Fix != nil {
+		rpt.Fix.Removed = r.symbols(rpt.Fix.Removed)
+		rpt.Fix.Manual = r.symbols(rpt.Fix.Manual)
+	}
+	if rpt.APISpec != nil {
+		rpt.APISpec.Undocumented = r.symbols(rpt.APISpec.Undocumented)
+		rpt.APISpec.Missing = r.symbols(rpt.APISpec.Missing)
+	}
+	if rpt.Baseline != nil {
+		rpt.Baseline.NewUnused = r.symbols(rpt.Baseline.NewUnused)
+		rpt.Baseline.Resolved = r.symbols(rpt.Baseline.Resolved)
+	}
+	if rpt.SimulatedRemoval != nil {
+		rpt.SimulatedRemoval.NewlyUnused = r.symbols(rpt.SimulatedRemoval.NewlyUnused)
+	}
+	if rpt.SinceTag != nil {
+		rpt.SinceTag.Added = r.symbols(rpt.SinceTag.Added)
+		rpt.SinceTag.Removed = r.symbols(rpt.SinceTag.Removed)
+		rpt.SinceTag.NewlyUnused = r.symbols(rpt.SinceTag.NewlyUnused)
+	}
+}
+
+// moduleFilter skips files in excluded modules. It is safe for concurrent use.
+type moduleFilter struct {
+	excluded map[string]interface{}
+	// guards modules
+	mu *sync.Mutex
+	// dir -> module path
+	modules map[string]string
+}
+
+func newModuleFilter(excluded []string) moduleFilter {
+	mf := moduleFilter{make(map[string]interface{}), &sync.Mutex{}, make(map[string]string)}
+	for _, mod := range excluded {
+		mf.excluded[mod] = exists
+	}
+	return mf
+}
+
+// skip reports whether file is in an excluded module.
+func (mf moduleFilter) skip(file string) bool {
+	if len(mf.excluded) == 0 {
+		return false
+	}
+	dir := filepath.Dir(file)
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+	mod, ok := mf.modules[dir]
+	if !ok {
+		// files outside of a module can't be in an excluded one
+		mod, _ = modulePath(dir)
+		mf.modules[dir] = mod
+	}
+	_, excluded := mf.excluded[mod]
+	return excluded
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// syncOutput serializes writes to several writers a line at a time, so that
+// lines written concurrently, like progress on stderr and the report on
+// stdout, never interleave.
+type syncOutput struct {
+	mu sync.Mutex
+}
+
+// writer returns a writer to w that holds partial lines back until they are
+// complete or flushed.
+func (o *syncOutput) writer(w io.Writer) *lineWriter {
+	return &lineWriter{out: o, w: w}
+}
+
+// lineWriter buffers writes until a line is complete.
+type lineWriter struct {
+	out *syncOutput
+	w   io.Writer
+	buf []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.out.mu.Lock()
+	defer lw.out.mu.Unlock()
+	lw.buf = append(lw.buf, p...)
+	i := bytes.LastIndexByte(lw.buf, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	_, err := lw.w.Write(lw.buf[:i+1])
+	lw.buf = append(lw.buf[:0], lw.buf[i+1:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any partial line that is left.
+func (lw *lineWriter) Flush() error {
+	lw.out.mu.Lock()
+	defer lw.out.mu.Unlock()
+	if len(lw.buf) == 0 {
+		return nil
+	}
+	_, err := lw.w.Write(lw.buf)
+	lw.buf = lw.buf[:0]
+	return err
+}
+
+// outputFormats are the supported --format values.
+var outputFormats = []string{"json", "text", "csv", "list"}
+
+// unusedRows returns a csv header, and a row with the symbol, kind, file and
+// line of each unused export. Exports without a position get empty columns.
+func unusedRows(unused []string, kinds map[string]symbols.Kind, positions map[string]token.Position) [][]string {
+	rows := [][]string{{"symbol", "kind", "file", "line"}}
+	for _, symbol := range unused {
+		row := []string{symbol, string(kinds[symbol]), "", ""}
+		if pos, ok := positions[symbol]; ok {
+			row[2], row[3] = pos.Filename, strconv.Itoa(pos.Line)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// writeText writes each unused export on its own line, and nothing else.
+func writeText(w io.Writer, unused []string) error {
+	for _, symbol := range unused {
+		if _, err := fmt.Fprintln(w, symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeList writes each unused export on its own line, sorted by symbol
+// whatever the report is sorted by, and nothing else.
+func writeList(w io.Writer, unused []string) error {
+	sorted := append([]string{}, unused...)
+	sort.Strings(sorted)
+	return writeText(w, sorted)
+}
+
+// writeCSV writes rows as csv.
+func writeCSV(w io.Writer, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return fmt.Errorf("failed to write csv: %w", err)
+	}
+	return nil
+}
+
+// ImportedUsage is an export and the files that reference it.
+type ImportedUsage struct {
+	Symbol string   `json:"symbol"`
+	UsedBy []string `json:"usedBy"`
+}
+
+// usagesReport is a Report whose Imported lists where each export is used.
+type usagesReport struct {
+	Report
+	Imported []ImportedUsage
+}
+
+// importedUsages lists the files that reference each of the imported symbols,
+// sorted.
+func importedUsages(imported []string, refs map[string]map[string]int) []ImportedUsage {
+	usages := make([]ImportedUsage, 0, len(imported))
+	for _, symbol := range imported {
+		usage := ImportedUsage{Symbol: symbol, UsedBy: []string{}}
+		for file := range refs[symbol] {
+			usage.UsedBy = sortedInsert(usage.UsedBy, file)
+		}
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// marshalReport writes rpt as indented json, with usages in place of its
+// Imported list if they are set.
+func marshalReport(rpt Report, usages []ImportedUsage) ([]byte, error) {
+	if usages == nil {
+		return json.MarshalIndent(rpt, "", "  ")
+	}
+	return json.MarshalIndent(usagesReport{rpt, usages}, "", "  ")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// expandPaths expands arg with expandPath. An arg starting with @ names a file
+// listing the paths instead, one per line, ignoring blank lines and lines
+// starting with #.
+func expandPaths(arg string) ([]string, error) {
+	if !strings.HasPrefix(arg, "@") {
+		return []string{expandPath(arg)}, nil
+	}
+	file := strings.TrimPrefix(arg, "@")
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read path list %s: %w", file, err)
+	}
+	lines, err := readPathList(b)
+	if err != nil {
+		return nil, fmt.Errorf("could not read path list %s: %w", file, err)
+	}
+	paths := []string{}
+	for _, line := range lines {
+		paths = append(paths, expandPath(line))
+	}
+	return paths, nil
+}
+
+// readPathList returns the lines of b in order, ignoring blank lines and lines
+// starting with #.
+func readPathList(b []byte) ([]string, error) {
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// loadProfileKey is the context key for the loadProfile that package loads are
+// timed into.
+type loadProfileKey struct{}
+
+// loadProfile sums the wall-clock time spent loading each package. It is safe
+// for concurrent use.
+type loadProfile struct {
+	// guards times
+	mu *sync.Mutex
+	// package -> time spent loading it
+	times map[string]time.Duration
+}
+
+func newLoadProfile() loadProfile {
+	return loadProfile{&sync.Mutex{}, make(map[string]time.Duration)}
+}
+
+// withLoadProfile returns a context in which package loads are timed into p.
+func withLoadProfile(ctx context.Context, p loadProfile) context.Context {
+	return context.WithValue(ctx, loadProfileKey{}, p)
+}
+
+// recordLoad adds d to the time spent loading pkg, if ctx has a loadProfile.
+func recordLoad(ctx context.Context, pkg string, d time.Duration) {
+	p, ok := ctx.Value(loadProfileKey{}).(loadProfile)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.times[pkg] += d
+}
+
+// write writes each package with the time spent loading it, slowest first.
+func (p loadProfile) write(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pkgs := make([]string, 0, len(p.times))
+	for pkg := range p.times {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if p.times[pkgs[i]] != p.times[pkgs[j]] {
+			return p.times[pkgs[i]] > p.times[pkgs[j]]
+		}
+		return pkgs[i] < pkgs[j]
+	})
+	if _, err := fmt.Fprintln(w, "package load times:"); err != nil {
+		return err
+	}
+	for _, pkg := range pkgs {
+		if _, err := fmt.Fprintf(w, "%v\t%s\n", p.times[pkg], pkg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// findRegexNames returns the names that patterns capture from the string
+// literals in to. Each pattern's first group is the name, which is either fully
+// qualified or relative to its package, like "Handler" or "Type.Method".
+func findRegexNames(ctx context.Context, to []string, excludeTo []string, tags buildTags, excludeModules moduleFilter, patterns []*regexp.Regexp) (map[string]interface{}, error) {
+	names := make(map[string]interface{})
+	// guards names, which fn fills from several goroutines
+	var mu sync.Mutex
+
+	fs := token.NewFileSet()
+	err := runOnFiles(ctx, to, excludeTo, func(file string) error {
+		if !tags.matchFile(file) || excludeModules.skip(file) {
+			return nil
+		}
+		f, err := parser.ParseFile(fs, file, nil, 0)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", file, err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			lit, ok := n.(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			for _, re := range patterns {
+				for _, match := range re.FindAllStringSubmatch(s, -1) {
+					if match[1] != "" {
+						names[match[1]] = exists
+					}
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan strings: %w", err)
+	}
+	return names, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"io"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// credits are the exports that count as used without a reference, by how
+// they were found.
+type credits struct {
+	// methods that may be called through an interface
+	iface map[string]interface{}
+	// exports used in examples in doc comments
+	docs map[string]interface{}
+	// exports named in a --ref-manifest
+	manifest map[string]interface{}
+	// exports a --ref-regex captured from a string literal
+	reflected map[string]interface{}
+}
+
+// credited reports whether symbol counts as used without a reference.
+func (c credits) credited(symbol string) bool {
+	for _, uses := range []map[string]interface{}{c.iface, c.docs, c.manifest, c.reflected} {
+		if _, ok := uses[symbol]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// newReport lists the exports, what refs references, and the exports that
+// neither refs nor anything in credited uses.
+func newReport(exports map[string]interface{}, refs map[string]map[string]int, credited credits) Report {
+	rpt := Report{
+		Exported:      []string{},
+		Imported:      []string{},
+		UnusedExports: []string{},
+	}
+	for k := range exports {
+		rpt.Exported = sortedInsert(rpt.Exported, k)
+		if _, ok := credited.docs[k]; ok {
+			rpt.DocumentedUsage = sortedInsert(rpt.DocumentedUsage, k)
+		}
+		if _, ok := credited.manifest[k]; ok {
+			rpt.ManifestUsage = sortedInsert(rpt.ManifestUsage, k)
+		}
+		if _, ok := credited.reflected[k]; ok {
+			rpt.PossiblyReflected = sortedInsert(rpt.PossiblyReflected, k)
+		}
+		if _, ok := refs[k]; !ok && !credited.credited(k) {
+			rpt.UnusedExports = sortedInsert(rpt.UnusedExports, k)
+		}
+	}
+	for k := range credited.iface {
+		// methods are credited if their receiver type is audited
+		if _, ok := exports[k[:strings.LastIndex(k, ".")]]; ok {
+			rpt.InterfaceUsed = sortedInsert(rpt.InterfaceUsed, k)
+		}
+	}
+	for k := range refs {
+		rpt.Imported = sortedInsert(rpt.Imported, k)
+	}
+	rpt.ReferenceCounts = referenceCounts(exports, refs)
+	return rpt
+}
+
+// needsReview splits the unused exports into the ones --verify found no trace
+// of, and the ones it found, keeping their order.
+func needsReview(unused []string, found map[string]interface{}) ([]string, []string) {
+	still, review := []string{}, []string(nil)
+	for _, k := range unused {
+		if _, ok := found[k]; ok {
+			review = append(review, k)
+		} else {
+			still = append(still, k)
+		}
+	}
+	return still, review
+}
+
+// conflictingExports lists the exports declared in more than one place, sorted
+// by export.
+func conflictingExports(conflicts map[string][]token.Position) []Conflict {
+	var list []Conflict
+	for symbol, declared := range conflicts {
+		conflict := Conflict{Export: symbol}
+		for _, pos := range declared {
+			conflict.Declarations = append(conflict.Declarations, Position{pos.Filename, pos.Line})
+		}
+		list = append(list, conflict)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Export < list[j].Export })
+	return list
+}
+
+// unusedPositions maps the unused exports to where they are declared.
+func unusedPositions(unused []string, positions map[string]token.Position) map[string]Position {
+	found := make(map[string]Position, len(unused))
+	for _, symbol := range unused {
+		if pos, ok := positions[symbol]; ok {
+			found[symbol] = Position{pos.Filename, pos.Line}
+		}
+	}
+	return found
+}
+
+// writeReport writes rpt in the format cfg asks for: a summary, the template,
+// the unused exports as text, a list or csv rows, or json with usages in place
+// of Imported if they are set.
+func writeReport(w io.Writer, cfg config, tmpl *template.Template, rpt Report, usages []ImportedUsage, rows [][]string) error {
+	switch {
+	case cfg.summaryOnly:
+		outB, err := json.Marshal(summarize(rpt))
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(outB))
+		return err
+	case tmpl != nil:
+		return renderTemplate(w, tmpl, rpt)
+	case cfg.format == "text":
+		return writeText(w, rpt.UnusedExports)
+	case cfg.format == "list":
+		return writeList(w, rpt.UnusedExports)
+	case cfg.format == "csv":
+		return writeCSV(w, rows)
+	default:
+		outB, err := marshalReport(rpt, usages)
+		if err != nil {
+			return fmt.Errorf("failed to marshal output: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(outB))
+		return err
+	}
+}
+
+// exitCode returns the exit code for the checks cfg asks for, explaining on
+// stderr why they failed. failing are the unused exports of the kinds in
+// --fail-on-kind.
+func exitCode(stderr io.Writer, cfg config, rpt Report, failing []string) int {
+	if cfg.enforceSpec && rpt.APISpec.violated() {
+		fmt.Fprintf(stderr, "exports don't match the API spec:\n")
+		for _, symbol := range rpt.APISpec.Undocumented {
+			fmt.Fprintf(stderr, "not in the spec: %s\n", symbol)
+		}
+		for _, symbol := range rpt.APISpec.Missing {
+			fmt.Fprintf(stderr, "not exported: %s\n", symbol)
+		}
+		return specExitCode
+	}
+	if len(rpt.ConsumerGroupOrphans) > 0 {
+		fmt.Fprintf(stderr, "%d exports aren't used by %s:\n%s\n", len(rpt.ConsumerGroupOrphans), cfg.requiredGroup, strings.Join(rpt.ConsumerGroupOrphans, "\n"))
+		return orphanExitCode
+	}
+	if cfg.failOnUnused && len(rpt.UnusedExports) > 0 {
+		fmt.Fprintf(stderr, "%d unused exports:\n%s\n", len(rpt.UnusedExports), strings.Join(rpt.UnusedExports, "\n"))
+		if !cfg.exitCodeCount {
+			return unusedExitCode
+		}
+	} else if len(failing) > 0 {
+		fmt.Fprintf(stderr, "%d unused exports of kind %s:\n%s\n", len(failing), cfg.failOnKind, strings.Join(failing, "\n"))
+		if !cfg.exitCodeCount {
+			return unusedExitCode
+		}
+	}
+	if cfg.exitCodeCount {
+		return countExitCode(len(rpt.UnusedExports))
+	}
+	return 0
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+)
+
+// reportSchema returns a JSON Schema describing Report. It is generated from
+// the type, so it can't drift from what is written. Imported is either the
+// list of symbols, or the usagesReport list of where each is used, with
+// --with-usages. Both match an empty list, so either may.
+func reportSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(Report{}))
+	properties := schema["properties"].(map[string]interface{})
+	usages, _ := reflect.TypeOf(usagesReport{}).FieldByName("Imported")
+	properties["Imported"] = map[string]interface{}{
+		"anyOf": []interface{}{properties["Imported"], typeSchema(usages.Type)},
+	}
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "refaudit report"
+	return schema
+}
+
+// typeSchema describes how encoding/json writes values of type t.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				// unexported
+				continue
+			}
+			name, omitEmpty := jsonName(field)
+			if name == "-" {
+				continue
+			}
+			schema := typeSchema(field.Type)
+			if !omitEmpty {
+				required = append(required, name)
+				if k := field.Type.Kind(); k == reflect.Ptr || k == reflect.Slice || k == reflect.Map {
+					// written as null when nil
+					schema["type"] = []string{schema["type"].(string), "null"}
+				}
+			}
+			properties[name] = schema
+		}
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// jsonName returns the name encoding/json writes field as, and whether it is
+// left out when empty.
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := strings.Split(field.Tag.Get("json"), ",")
+	name := tag[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, opt := range tag[1:] {
+		if opt == "omitempty" {
+			return name, true
+		}
+	}
+	return name, false
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSchema(t *testing.T) {
+	code, schema := runArgs(t, jsonSchemaArg)
+	require.Equal(t, 0, code)
+	// compiling checks the schema against the draft 7 meta-schema
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	require.NoError(t, compiler.AddResource("report.json", strings.NewReader(schema)))
+	compiled, err := compiler.Compile("report.json")
+	require.NoError(t, err)
+
+	for _, args := range [][]string{
+		{fromArg, "./internal/dummy", toArg, "./testdata/consumer"},
+		{fromArg, "./internal/dummy", toArg, "./testdata/consumer", groupByArg, "module", countByConsumerArg, singleConsumerArg, positionsArg, blankImportsArg},
+		{fromArg, "./internal/dummy", toArg, "./testdata/consumer", withUsagesArg},
+	} {
+		code, out := runArgs(t, args...)
+		require.Equal(t, 0, code)
+		var rpt interface{}
+		require.NoError(t, json.Unmarshal([]byte(out), &rpt))
+		require.NoError(t, compiled.Validate(rpt), strings.Join(args, " "))
+	}
+}
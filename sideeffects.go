@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"sync"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// findBlankImports returns the packages that files in to only ever import
+// blank, for their side effects, sorted.
+func findBlankImports(ctx context.Context, to []string, excludeTo []string, tags buildTags, excludeModules moduleFilter) ([]string, error) {
+	blank := make(map[string]interface{})
+	named := make(map[string]interface{})
+	var mu sync.Mutex
+
+	fs := token.NewFileSet()
+	err := runOnFiles(ctx, to, excludeTo, func(file string) error {
+		if !tags.matchFile(file) || excludeModules.skip(file) {
+			return nil
+		}
+		f, err := parser.ParseFile(fs, file, nil, parser.ImportsOnly)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", file, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, spec := range f.Imports {
+			pkgPath := symbols.NormalizePkgPath(spec.Path.Value)
+			if spec.Name != nil && spec.Name.Name == "_" {
+				blank[pkgPath] = exists
+			} else {
+				named[pkgPath] = exists
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find blank imports: %w", err)
+	}
+
+	only := []string{}
+	for pkgPath := range blank {
+		if _, ok := named[pkgPath]; !ok {
+			only = sortedInsert(only, pkgPath)
+		}
+	}
+	return only, nil
+}
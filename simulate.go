@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// RemovalDiff lists the exports only a consumer uses.
+type RemovalDiff struct {
+	// Consumer is the directory that was left out.
+	Consumer string
+	// NewlyUnused lists exports that would be unused without Consumer.
+	NewlyUnused []string
+}
+
+// simulateRemoval finds the exports that would become unused if the consumer
+// in dir was removed, by finding the imports again without it.
+func simulateRemoval(ctx context.Context, dir string, in inputs, rpt Report) (*RemovalDiff, error) {
+	excludeTo := append(append([]string{}, in.excludeTo...), dir)
+	refs, err := findImports(ctx, in.to, excludeTo, in.tags, in.excludeModules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate removing %s: %w", dir, err)
+	}
+
+	credited := make(map[string]interface{})
+	for _, symbol := range rpt.DocumentedUsage {
+		credited[symbol] = exists
+	}
+	unused := make(map[string]interface{})
+	for _, symbol := range rpt.UnusedExports {
+		unused[symbol] = exists
+	}
+	without := []string{}
+	for _, symbol := range rpt.Exported {
+		_, referenced := refs[symbol]
+		_, documented := credited[symbol]
+		if _, ok := unused[symbol]; ok || (!referenced && !documented) {
+			without = append(without, symbol)
+		}
+	}
+
+	newlyUnused, _ := diffSymbols(unused, without)
+	return &RemovalDiff{Consumer: dir, NewlyUnused: newlyUnused}, nil
+}
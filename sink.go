@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// sinkBackoff is how long to wait before the first retry. It doubles on every
+// retry after that.
+var sinkBackoff = time.Second
+
+// postReport POSTs a JSON report to url, retrying up to retries times when
+// the request fails or the server responds with a 5xx status. Proxies are taken
+// from the standard environment variables.
+func postReport(ctx context.Context, url string, report []byte, timeout time.Duration, retries int) error {
+	client := &http.Client{Timeout: timeout}
+	backoff := sinkBackoff
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		var retry bool
+		if retry, err = postOnce(ctx, client, url, report); err == nil {
+			return nil
+		} else if !retry {
+			return fmt.Errorf("could not send report to %s: %w", url, err)
+		}
+	}
+	return fmt.Errorf("could not send report to %s after %d attempts: %w", url, retries+1, err)
+}
+
+// postOnce sends a single request, and returns an error if it didn't succeed,
+// along with whether it is worth retrying.
+func postOnce(ctx context.Context, client *http.Client, url string, report []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(report))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode >= 500, fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return false, nil
+}
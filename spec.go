@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// specExitCode is returned when --enforce-spec is set and the exports don't
+// match the API spec.
+const specExitCode = 4
+
+// SpecDiff compares the exports with an API spec.
+type SpecDiff struct {
+	// Undocumented lists exports that aren't in the spec.
+	Undocumented []string
+	// Missing lists symbols in the spec that aren't exported.
+	Missing []string
+}
+
+// loadSpec reads an API spec, which lists one fully-qualified symbol per line,
+// ignoring blank lines and lines starting with #.
+func loadSpec(file string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read API spec %s: %w", file, err)
+	}
+	spec, err := parseList(b)
+	if err != nil {
+		return nil, fmt.Errorf("could not read API spec %s: %w", file, err)
+	}
+	return spec, nil
+}
+
+// diffSpec compares the exports with an API spec.
+func diffSpec(spec map[string]interface{}, exported []string) *SpecDiff {
+	undocumented, missing := diffSymbols(spec, exported)
+	return &SpecDiff{Undocumented: undocumented, Missing: missing}
+}
+
+// violated reports whether the exports don't match the spec.
+func (d *SpecDiff) violated() bool {
+	return len(d.Undocumented) > 0 || len(d.Missing) > 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// stdinArg is the --from or --to path that stands for a file read from stdin.
+const stdinArg = "-"
+
+// stdinName is the file name that source read from stdin is given.
+const stdinName = "<stdin>"
+
+// stdin is where source is read from for stdinArg.
+var stdin io.Reader = os.Stdin
+
+// parseStdin parses the go source in r as a single file named stdinName.
+func parseStdin(fs *token.FileSet, r io.Reader) (*ast.File, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read stdin: %w", err)
+	}
+	f, err := parser.ParseFile(fs, stdinName, src, parser.AllErrors)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", stdinName, err)
+	}
+	return f, nil
+}
+
+// addFile adds the exports of f, which belongs to the package pkgPath, to the
+// scan, in place of any it already had by the same name.
+func (scan exportScan) addFile(fs *token.FileSet, f *ast.File, pkgPath string) {
+	scan.packages[pkgPath] = exists
+	for symbol, export := range symbols.FindExports(f, pkgPath) {
+		scan.exports[symbol] = exists
+		scan.positions[symbol] = fs.Position(export.Pos)
+		scan.kinds[symbol] = export.Kind
+	}
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// surfaceHash returns a hash of the exported symbols and their kinds, which
+// only changes when the export surface does. exported must be sorted.
+func surfaceHash(exported []string, kinds map[string]symbols.Kind) string {
+	h := sha256.New()
+	for _, symbol := range exported {
+		fmt.Fprintf(h, "%s %s\n", symbol, kinds[symbol])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,521 @@
+// Package symbols finds the exported symbols a go file declares and the
+// package-level symbols it references, keyed as "import/path.Name", or
+// "import/path.Type.Name" for methods and fields.
+//
+// The visitors only look at the syntax of a single file, so they work on files
+// from any parser, including the ones in a go/analysis pass:
+//
+//	for _, f := range pass.Files {
+//		refs := symbols.FindRefs(f)
+//		...
+//	}
+package symbols
+
+import (
+	"go/ast"
+	"go/token"
+	"path"
+	"strings"
+)
+
+var exists = struct{}{}
+
+// Kind is the kind of declaration an export is.
+type Kind string
+
+const (
+	Func  Kind = "func"
+	Type  Kind = "type"
+	Var   Kind = "var"
+	Const Kind = "const"
+	// Method is an exported method of an exported type, keyed as
+	// "import/path.Type.Method".
+	Method Kind = "method"
+	// Field is an exported field of an exported struct type, keyed as
+	// "import/path.Type.Field".
+	Field Kind = "field"
+	// InterfaceMethod is an exported method declared by an exported interface
+	// type, keyed as "import/path.Type.Method".
+	InterfaceMethod Kind = "interface-method"
+)
+
+// Kinds lists every Kind.
+var Kinds = []Kind{Func, Type, Var, Const, Method, Field, InterfaceMethod}
+
+// Export is where and how an exported symbol is declared.
+type Export struct {
+	Pos  token.Pos
+	Kind Kind
+}
+
+// FindExports returns the exported symbols declared in f, which belongs to the
+// package pkgPath, mapped to their declarations.
+func FindExports(f *ast.File, pkgPath string) map[string]Export {
+	v := NewExportVisitor(f, pkgPath)
+	ast.Walk(v, f)
+	return v.Exports()
+}
+
+// FindRefs returns the symbols from imported packages that f references.
+func FindRefs(f *ast.File) map[string]interface{} {
+	v := NewRefVisitor(f)
+	ast.Walk(v, f)
+	return v.Refs()
+}
+
+// CountRefs returns the symbols from imported packages that f references,
+// mapped to how many times it does.
+func CountRefs(f *ast.File) map[string]int {
+	v := NewRefVisitor(f)
+	ast.Walk(v, f)
+	return v.Counts()
+}
+
+// FindMethodCalls returns the names of the exported methods f calls on values,
+// like Read in r.Read(p), mapped to how many times it does. Without type
+// information, which type's method is called can't be told.
+func FindMethodCalls(f *ast.File) map[string]int {
+	imports := NewRefVisitor(f).Imports()
+	calls := make(map[string]int)
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := unparen(call.Fun).(*ast.SelectorExpr)
+		if !ok || !sel.Sel.IsExported() {
+			return true
+		}
+		// package names aren't resolved to objects, unlike locals that
+		// shadow them
+		if ident, ok := unparen(sel.X).(*ast.Ident); ok && ident.Obj == nil {
+			if _, ok := imports[ident.Name]; ok {
+				return true
+			}
+		}
+		calls[sel.Sel.Name]++
+		return true
+	})
+	return calls
+}
+
+// ExportVisitor tracks public exports. f must have been parsed with object
+// resolution, which is the go/parser default.
+type ExportVisitor struct {
+	f       *ast.File
+	pkgPath string
+	// symbol -> declaration
+	exports map[string]Export
+}
+
+// NewExportVisitor returns a visitor for f, which belongs to the package pkgPath.
+func NewExportVisitor(f *ast.File, pkgPath string) ExportVisitor {
+	return ExportVisitor{f, pkgPath, make(map[string]Export)}
+}
+
+// Exports returns the exports found so far, mapped to their declarations.
+func (v ExportVisitor) Exports() map[string]Export {
+	return v.exports
+}
+
+func (v ExportVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		return nil
+	}
+
+	switch d := n.(type) {
+	case *ast.AssignStmt:
+		if d.Tok != token.DEFINE {
+			return v
+		}
+		for _, name := range d.Lhs {
+			v.add(name, Var)
+		}
+
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			v.add(d.Name, Func)
+		} else {
+			v.addMethod(d)
+		}
+	case *ast.GenDecl:
+		if d.Tok == token.VAR || d.Tok == token.CONST {
+			kind := Var
+			if d.Tok == token.CONST {
+				kind = Const
+			}
+			for _, spec := range d.Specs {
+				if value, ok := spec.(*ast.ValueSpec); ok {
+					for _, name := range value.Names {
+						v.add(name, kind)
+					}
+				}
+			}
+		} else if d.Tok == token.TYPE {
+			for _, spec := range d.Specs {
+				if value, ok := spec.(*ast.TypeSpec); ok {
+					v.add(value.Name, Type)
+					v.addFields(value)
+					v.addInterfaceMethods(value)
+				}
+			}
+		}
+	}
+
+	return v
+}
+
+func (v ExportVisitor) add(n ast.Node, kind Kind) {
+	ident, ok := n.(*ast.Ident)
+	if !ok {
+		return
+	}
+	if ident.Name == "_" || ident.Name == "" {
+		return
+	}
+	if ident.Obj != nil && ident.Obj.Pos() == ident.Pos() {
+		if ident.IsExported() {
+			v.exports[v.pkgPath+"."+ident.Name] = Export{ident.Pos(), kind}
+		}
+	}
+}
+
+// addMethod adds fn, a method, if both it and its receiver's type are exported.
+func (v ExportVisitor) addMethod(fn *ast.FuncDecl) {
+	if len(fn.Recv.List) == 0 || !fn.Name.IsExported() {
+		return
+	}
+	if recv, ok := baseType(fn.Recv.List[0].Type).(*ast.Ident); ok && recv.IsExported() {
+		v.exports[v.pkgPath+"."+recv.Name+"."+fn.Name.Name] = Export{fn.Name.Pos(), Method}
+	}
+}
+
+// addFields adds the exported fields of spec, if it declares an exported struct
+// type. Embedded fields are left out.
+func (v ExportVisitor) addFields(spec *ast.TypeSpec) {
+	st, ok := spec.Type.(*ast.StructType)
+	if !ok || !spec.Name.IsExported() || spec.Name.Obj == nil || spec.Name.Obj.Pos() != spec.Name.Pos() {
+		return
+	}
+	for _, field := range st.Fields.List {
+		for _, name := range field.Names {
+			if name.IsExported() {
+				v.exports[v.pkgPath+"."+spec.Name.Name+"."+name.Name] = Export{name.Pos(), Field}
+			}
+		}
+	}
+}
+
+// addInterfaceMethods adds the exported methods of spec, if it declares an
+// exported interface type. Embedded interfaces are left out.
+func (v ExportVisitor) addInterfaceMethods(spec *ast.TypeSpec) {
+	it, ok := spec.Type.(*ast.InterfaceType)
+	if !ok || !spec.Name.IsExported() || spec.Name.Obj == nil || spec.Name.Obj.Pos() != spec.Name.Pos() {
+		return
+	}
+	for _, method := range it.Methods.List {
+		for _, name := range method.Names {
+			if name.IsExported() {
+				v.exports[v.pkgPath+"."+spec.Name.Name+"."+name.Name] = Export{name.Pos(), InterfaceMethod}
+			}
+		}
+	}
+}
+
+// RefVisitor tracks import references.
+type RefVisitor struct {
+	f *ast.File
+	// symbol -> exists
+	refs map[string]interface{}
+	// symbol -> occurrences
+	counts map[string]int
+	// alias -> real pkg
+	importedPkgs map[string]string
+	// packages imported with a dot
+	dotImports []string
+}
+
+// NewRefVisitor returns a visitor for f.
+func NewRefVisitor(f *ast.File) RefVisitor {
+	ip := make(map[string]string)
+	dots := []string{}
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			importSpec, ok := spec.(*ast.ImportSpec)
+			if ok {
+				impName := NormalizePkgPath(importSpec.Path.Value)
+				alias := ImportAlias(impName)
+				if importSpec.Name != nil {
+					alias = importSpec.Name.Name
+				}
+				switch alias {
+				case "_":
+					// imported for side effects, nothing can be selected from it
+					continue
+				case ".":
+					dots = append(dots, impName)
+					continue
+				}
+				ip[alias] = impName
+			}
+
+		}
+	}
+
+	return RefVisitor{f, make(map[string]interface{}), make(map[string]int), ip, dots}
+}
+
+// Refs returns the symbols referenced so far.
+func (v RefVisitor) Refs() map[string]interface{} {
+	return v.refs
+}
+
+// Counts returns how many times each symbol has been referenced so far.
+func (v RefVisitor) Counts() map[string]int {
+	return v.counts
+}
+
+// ref records an occurrence of symbol.
+func (v RefVisitor) ref(symbol string) {
+	v.refs[symbol] = exists
+	v.counts[symbol]++
+}
+
+// Imports maps the names f refers to imported packages by to their import paths.
+func (v RefVisitor) Imports() map[string]string {
+	return v.importedPkgs
+}
+
+// Visit records the package-qualified selectors in n. Types are walked like any
+// other expression, so selectors in type positions, like []pkg.T, map[pkg.K]pkg.V,
+// chan pkg.T, func(pkg.T) and type parameter constraints, count too.
+func (v RefVisitor) Visit(n ast.Node) ast.Visitor {
+	if n == nil {
+		return nil
+	}
+
+	if ident, ok := n.(*ast.Ident); ok {
+		// names from dot imports are used bare, and can't be told apart, so
+		// exported names the file doesn't declare count for every dot import
+		if ident.Obj == nil && ident.IsExported() {
+			for _, imp := range v.dotImports {
+				v.ref(imp + "." + ident.Name)
+			}
+		}
+		return nil
+	}
+
+	if lit, ok := n.(*ast.CompositeLit); ok {
+		v.keyedFields(lit.Type, lit)
+		return v
+	}
+
+	if d, ok := n.(*ast.SelectorExpr); ok {
+		// generated code sometimes wraps the package in parentheses
+		x := unparen(d.X)
+		if lit, ok := x.(*ast.CompositeLit); ok {
+			// a field of a literal, like pkg.Config{}.Timeout
+			if typ, ok := lit.Type.(*ast.SelectorExpr); ok {
+				if pkgIdent, ok := unparen(typ.X).(*ast.Ident); ok {
+					if imp, ok := v.importedPkgs[pkgIdent.Name]; ok {
+						v.ref(imp + "." + typ.Sel.Name + "." + d.Sel.Name)
+					}
+				}
+			}
+		} else if typ, ok := x.(*ast.SelectorExpr); ok {
+			// a method expression, like pkg.Type.Method
+			if pkgIdent, ok := unparen(typ.X).(*ast.Ident); ok {
+				if imp, ok := v.importedPkgs[pkgIdent.Name]; ok {
+					v.ref(imp + "." + typ.Sel.Name + "." + d.Sel.Name)
+				}
+			}
+		} else if xIdent, ok := x.(*ast.Ident); ok {
+			if imp, ok := v.importedPkgs[xIdent.Name]; ok {
+				v.ref(imp + "." + d.Sel.Name)
+			} else if typ := v.declaredType(xIdent); typ != "" {
+				// a field or method of a value of an imported type, like
+				// cfg.Timeout
+				v.ref(typ + "." + d.Sel.Name)
+			}
+		}
+		// the selected name is a field or method, not a package-level name
+		ast.Walk(v, d.X)
+		return nil
+	}
+	return v
+}
+
+// declaredType returns the imported type ident is declared with, like
+// "import/path.Config" for cfg in var cfg pkg.Config, cfg := &pkg.Config{} or
+// func f(cfg *pkg.Config), or "" if the syntax doesn't tell.
+func (v RefVisitor) declaredType(ident *ast.Ident) string {
+	if ident.Obj == nil || ident.Obj.Kind != ast.Var {
+		return ""
+	}
+	switch decl := ident.Obj.Decl.(type) {
+	case *ast.Field:
+		return v.typeName(decl.Type)
+	case *ast.ValueSpec:
+		if decl.Type != nil {
+			return v.typeName(decl.Type)
+		}
+		for i, name := range decl.Names {
+			if name.Name == ident.Name && i < len(decl.Values) {
+				return v.literalType(decl.Values[i])
+			}
+		}
+	case *ast.AssignStmt:
+		if len(decl.Lhs) != len(decl.Rhs) {
+			return ""
+		}
+		for i, lhs := range decl.Lhs {
+			if name, ok := lhs.(*ast.Ident); ok && name.Name == ident.Name {
+				return v.literalType(decl.Rhs[i])
+			}
+		}
+	}
+	return ""
+}
+
+// literalType returns the imported type of x, if it is a literal like
+// pkg.Config{} or &pkg.Config{}, or "".
+func (v RefVisitor) literalType(x ast.Expr) string {
+	if lit, ok := unparen(x).(*ast.CompositeLit); ok && lit.Type != nil {
+		return v.typeName(lit.Type)
+	}
+	return ""
+}
+
+// typeName returns typ as "import/path.Type", if it is an imported type or a
+// pointer to one, or "".
+func (v RefVisitor) typeName(typ ast.Expr) string {
+	sel, ok := baseType(typ).(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	pkgIdent, ok := unparen(sel.X).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if imp, ok := v.importedPkgs[pkgIdent.Name]; ok {
+		return imp + "." + sel.Sel.Name
+	}
+	return ""
+}
+
+// keyedFields records the keys of lit as fields of typ, like Timeout in
+// pkg.Config{Timeout: 5}, if typ is an imported type. Literals nested in a
+// slice, array or map literal with their type elided count as typ's elements.
+func (v RefVisitor) keyedFields(typ ast.Expr, lit *ast.CompositeLit) {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	var elem ast.Expr
+	switch t := typ.(type) {
+	case *ast.SelectorExpr:
+		pkgIdent, ok := unparen(t.X).(*ast.Ident)
+		if !ok {
+			return
+		}
+		imp, ok := v.importedPkgs[pkgIdent.Name]
+		if !ok {
+			return
+		}
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				if key, ok := kv.Key.(*ast.Ident); ok {
+					v.ref(imp + "." + t.Sel.Name + "." + key.Name)
+				}
+			}
+		}
+		return
+	case *ast.ArrayType:
+		elem = t.Elt
+	case *ast.MapType:
+		elem = t.Value
+	default:
+		return
+	}
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			elt = kv.Value
+		}
+		if u, ok := elt.(*ast.UnaryExpr); ok && u.Op == token.AND {
+			elt = u.X
+		}
+		if nested, ok := elt.(*ast.CompositeLit); ok && nested.Type == nil {
+			v.keyedFields(elem, nested)
+		}
+	}
+}
+
+// baseType strips the pointer and type arguments from typ, leaving the named
+// type in *T, Set[T] or Pair[K, V].
+func baseType(typ ast.Expr) ast.Expr {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch index := typ.(type) {
+	case *ast.IndexExpr:
+		typ = index.X
+	case *ast.IndexListExpr:
+		typ = index.X
+	}
+	return typ
+}
+
+// unparen strips the parentheses and address-of operators around x.
+func unparen(x ast.Expr) ast.Expr {
+	for {
+		switch e := x.(type) {
+		case *ast.ParenExpr:
+			x = e.X
+		case *ast.UnaryExpr:
+			if e.Op != token.AND {
+				return x
+			}
+			x = e.X
+		default:
+			return x
+		}
+	}
+}
+
+// NormalizePkgPath canonicalizes an import path so that exports and references
+// to the same package produce identical keys.
+func NormalizePkgPath(pkgPath string) string {
+	return path.Clean(strings.Trim(pkgPath, "\""))
+}
+
+// ImportAlias guesses the name a package is referred to by when it is imported
+// without an explicit name. Major version suffixes like "/v2" or "gopkg.in/yaml.v3"
+// are not part of the package name.
+func ImportAlias(pkgPath string) string {
+	splits := strings.Split(pkgPath, "/")
+	alias := splits[len(splits)-1]
+	if len(splits) > 1 && isMajorVersion(alias) {
+		alias = splits[len(splits)-2]
+	}
+	if i := strings.LastIndex(alias, ".v"); i > 0 && isMajorVersion(alias[i+1:]) {
+		alias = alias[:i]
+	}
+	return alias
+}
+
+// isMajorVersion reports whether elem is a major version element like "v2".
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package symbols
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selector builds x.sel.
+func selector(x ast.Expr, sel string) *ast.SelectorExpr {
+	return &ast.SelectorExpr{X: x, Sel: ast.NewIdent(sel)}
+}
+
+// testFile builds the equivalent of:
+//
+//	package lib
+//
+//	import (
+//		"fmt"
+//		b "example.com/foo/bar"
+//		_ "example.com/side"
+//	)
+//
+//	func Exported() {
+//		fmt.Println(b.Call, x.Field)
+//		(b).Other()
+//		_ = (&b.Config{}).Timeout
+//	}
+//
+//	var hidden, Visible int
+//
+//	type T struct {
+//		Field, hidden int
+//	}
+//
+//	func (t *T) Method() {}
+//
+//	func (p Pair[K, V]) Get() {}
+func testFile() *ast.File {
+	fn := &ast.FuncDecl{
+		Name: &ast.Ident{NamePos: 10, Name: "Exported"},
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  selector(ast.NewIdent("fmt"), "Println"),
+				Args: []ast.Expr{selector(ast.NewIdent("b"), "Call"), selector(ast.NewIdent("x"), "Field")},
+			}},
+			&ast.ExprStmt{X: &ast.CallExpr{Fun: selector(&ast.ParenExpr{X: ast.NewIdent("b")}, "Other")}},
+			&ast.AssignStmt{
+				Lhs: []ast.Expr{ast.NewIdent("_")},
+				Tok: token.ASSIGN,
+				Rhs: []ast.Expr{selector(&ast.ParenExpr{X: &ast.UnaryExpr{
+					Op: token.AND,
+					X:  &ast.CompositeLit{Type: selector(ast.NewIdent("b"), "Config")},
+				}}, "Timeout")},
+			},
+		}},
+	}
+	fn.Name.Obj = &ast.Object{Kind: ast.Fun, Name: fn.Name.Name, Decl: fn}
+
+	vars := &ast.ValueSpec{
+		Names: []*ast.Ident{{NamePos: 20, Name: "hidden"}, {NamePos: 30, Name: "Visible"}},
+		Type:  ast.NewIdent("int"),
+	}
+	for _, name := range vars.Names {
+		name.Obj = &ast.Object{Kind: ast.Var, Name: name.Name, Decl: vars}
+	}
+
+	typ := &ast.TypeSpec{Name: &ast.Ident{NamePos: 40, Name: "T"}, Type: &ast.StructType{Fields: &ast.FieldList{List: []*ast.Field{{
+		Names: []*ast.Ident{{NamePos: 50, Name: "Field"}, {NamePos: 60, Name: "hidden"}},
+		Type:  ast.NewIdent("int"),
+	}}}}}
+	typ.Name.Obj = &ast.Object{Kind: ast.Typ, Name: typ.Name.Name, Decl: typ}
+
+	method := &ast.FuncDecl{
+		Recv: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("t")}, Type: &ast.StarExpr{X: ast.NewIdent("T")}}}},
+		Name: &ast.Ident{NamePos: 70, Name: "Method"},
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{},
+	}
+
+	generic := &ast.FuncDecl{
+		Recv: &ast.FieldList{List: []*ast.Field{{Names: []*ast.Ident{ast.NewIdent("p")}, Type: &ast.IndexListExpr{
+			X:       ast.NewIdent("Pair"),
+			Indices: []ast.Expr{ast.NewIdent("K"), ast.NewIdent("V")},
+		}}}},
+		Name: &ast.Ident{NamePos: 80, Name: "Get"},
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{},
+	}
+
+	imports := &ast.GenDecl{Tok: token.IMPORT, Specs: []ast.Spec{
+		&ast.ImportSpec{Path: &ast.BasicLit{Kind: token.STRING, Value: `"fmt"`}},
+		&ast.ImportSpec{Name: ast.NewIdent("b"), Path: &ast.BasicLit{Kind: token.STRING, Value: `"example.com/foo/bar"`}},
+		&ast.ImportSpec{Name: ast.NewIdent("_"), Path: &ast.BasicLit{Kind: token.STRING, Value: `"example.com/side"`}},
+	}}
+
+	return &ast.File{
+		Name: ast.NewIdent("lib"),
+		Decls: []ast.Decl{
+			imports,
+			fn,
+			&ast.GenDecl{Tok: token.VAR, Specs: []ast.Spec{vars}},
+			&ast.GenDecl{Tok: token.TYPE, Specs: []ast.Spec{typ}},
+			method,
+			generic,
+		},
+	}
+}
+
+func TestFindExports(t *testing.T) {
+	exports := FindExports(testFile(), "example.com/lib")
+	assert.Equal(t, map[string]Export{
+		"example.com/lib.Exported": {10, Func},
+		"example.com/lib.Visible":  {30, Var},
+		"example.com/lib.T":        {40, Type},
+		"example.com/lib.T.Field":  {50, Field},
+		"example.com/lib.T.Method": {70, Method},
+		"example.com/lib.Pair.Get": {80, Method},
+	}, exports)
+}
+
+func TestFindRefs(t *testing.T) {
+	f := testFile()
+	v := NewRefVisitor(f)
+	assert.Equal(t, map[string]string{"fmt": "fmt", "b": "example.com/foo/bar"}, v.Imports())
+	ast.Walk(v, f)
+	assert.Equal(t, map[string]interface{}{
+		"fmt.Println":                        exists,
+		"example.com/foo/bar.Call":           exists,
+		"example.com/foo/bar.Other":          exists,
+		"example.com/foo/bar.Config":         exists,
+		"example.com/foo/bar.Config.Timeout": exists,
+	}, v.Refs())
+	assert.Equal(t, v.Refs(), FindRefs(f))
+	assert.Equal(t, map[string]int{
+		"fmt.Println":                        1,
+		"example.com/foo/bar.Call":           1,
+		"example.com/foo/bar.Other":          1,
+		"example.com/foo/bar.Config":         1,
+		"example.com/foo/bar.Config.Timeout": 1,
+	}, v.Counts())
+	assert.Equal(t, v.Counts(), CountRefs(f))
+}
+
+func TestFindMethodCalls(t *testing.T) {
+	f, err := parser.ParseFile(token.NewFileSet(), "calls.go", `package calls
+
+import (
+	"os"
+
+	"example.com/lib"
+)
+
+func calls(r lib.Reader) {
+	r.Read(nil)
+	(r).Read(nil)
+	r.close()
+	lib.Open()
+	os.Exit(0)
+}
+
+func shadowed(lib *os.File) {
+	lib.Close()
+}
+`, 0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"Read": 2, "Close": 1}, FindMethodCalls(f))
+}
+
+func TestImportAlias(t *testing.T) {
+	assert.Equal(t, "fmt", ImportAlias("fmt"))
+	assert.Equal(t, "packages", ImportAlias("golang.org/x/tools/go/packages"))
+	assert.Equal(t, "lib", ImportAlias("github.com/me/lib/v2"))
+	assert.Equal(t, "yaml", ImportAlias("gopkg.in/yaml.v3"))
+	assert.Equal(t, "v2", ImportAlias("v2"))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"go/build"
+	"path/filepath"
+	"strings"
+)
+
+// buildTags maps --to directories to the build tags their files need. The
+// tags under "" apply to every file.
+type buildTags map[string][]string
+
+// forFile returns the tags for every file, and for the most specific directory
+// file is in.
+func (bt buildTags) forFile(file string) []string {
+	best := ""
+	for dir := range bt {
+		if dir != "" && isExcluded(file, []string{dir}) && len(dir) > len(best) {
+			best = dir
+		}
+	}
+	if best == "" {
+		return bt[""]
+	}
+	return append(append([]string{}, bt[""]...), bt[best]...)
+}
+
+// matchFile reports whether file's build constraints are satisfied for this
+// platform with its directory's tags. Files whose constraints can't be read
+// are kept.
+func (bt buildTags) matchFile(file string) bool {
+	ctx := build.Default
+	ctx.BuildTags = bt.forFile(file)
+	ok, err := ctx.MatchFile(filepath.Dir(file), filepath.Base(file))
+	return err != nil || ok
+}
+
+// buildFlags returns the go build flags to load the packages in dir with.
+func (bt buildTags) buildFlags(dir string) []string {
+	tags := bt.forFile(dir)
+	if len(tags) == 0 {
+		return nil
+	}
+	return []string{"-tags=" + strings.Join(tags, ",")}
+}
+
+// mapped returns the tags with their directories mapped by fn.
+func (bt buildTags) mapped(fn func(string) string) buildTags {
+	mapped := make(buildTags, len(bt))
+	for dir, tags := range bt {
+		mapped[fn(dir)] = tags
+	}
+	return mapped
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs are the helpers available to --template files, in addition to
+// the text/template builtins.
+var templateFuncs = template.FuncMap{
+	// join concatenates a list of symbols with a separator
+	"join": strings.Join,
+	// count returns the length of any list in the report
+	"count": func(list interface{}) int {
+		return reflect.ValueOf(list).Len()
+	},
+}
+
+// parseTemplate reads a text/template to render the Report with.
+func parseTemplate(file string) (*template.Template, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read template %s: %w", file, err)
+	}
+	tmpl, err := template.New(file).Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse template %s: %w", file, err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate writes the report to w using tmpl.
+func renderTemplate(w io.Writer, tmpl *template.Template, rpt Report) error {
+	if err := tmpl.Execute(w, rpt); err != nil {
+		return fmt.Errorf("could not render template: %w", err)
+	}
+	return nil
+}
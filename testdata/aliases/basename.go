@@ -0,0 +1,12 @@
+package aliases
+
+import (
+	_ "example.com/blank/bar"
+	. "example.com/dot/bar"
+	"example.com/real/bar"
+)
+
+func basename() {
+	bar.Real()
+	Dotted()
+}
@@ -0,0 +1,12 @@
+// aliases imports packages under names that collide with other packages.
+package aliases
+
+import (
+	a "example.com/foo/bar"
+	fmt "example.com/notfmt"
+)
+
+func renamed() {
+	a.Call()
+	fmt.Println()
+}
@@ -0,0 +1,7 @@
+package consumer
+
+import "example.com/archived/lib"
+
+func use() {
+	lib.Used()
+}
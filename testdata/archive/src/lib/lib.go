@@ -0,0 +1,6 @@
+// lib is audited from an archive, used in tests.
+package lib
+
+func Used() {}
+
+func Unused() {}
@@ -0,0 +1,4 @@
+// dup is one of two copies of a package, used in tests.
+package dup
+
+func Shared() {}
@@ -0,0 +1,6 @@
+// dup is one of two copies of a package, used in tests.
+package dup
+
+func Shared(x int) {}
+
+func OnlyB() {}
@@ -0,0 +1,6 @@
+//go:build refaudit_extra
+// +build refaudit_extra
+
+package constraints
+
+func Extra() {}
@@ -0,0 +1,4 @@
+// constraints has exports behind build constraints, used in tests.
+package constraints
+
+func Common() {}
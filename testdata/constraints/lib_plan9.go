@@ -0,0 +1,3 @@
+package constraints
+
+func Plan9() {}
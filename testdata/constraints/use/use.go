@@ -0,0 +1,10 @@
+//go:build refaudit_extra
+// +build refaudit_extra
+
+package use
+
+import "github.com/launchdarkly-labs/refaudit/testdata/constraints"
+
+func use() {
+	constraints.Common()
+}
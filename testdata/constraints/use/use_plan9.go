@@ -0,0 +1,7 @@
+package use
+
+import "github.com/launchdarkly-labs/refaudit/testdata/constraints"
+
+func plan9() {
+	constraints.Plan9()
+}
@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+var wrapped = struct {
+	C dummy.Config
+}{}
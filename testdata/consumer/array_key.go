@@ -0,0 +1,5 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+var names = [...]string{dummy.IdxA: "a", dummy.IdxB: "b"}
@@ -0,0 +1,6 @@
+package consumer
+
+import (
+	// registers nothing, but is imported for side effects all the same
+	_ "github.com/launchdarkly-labs/refaudit/internal/dummy/noexports"
+)
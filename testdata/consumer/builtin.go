@@ -0,0 +1,16 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func allocate() int {
+	buf := make(dummy.Buffer, 0, 16)
+	_ = new(dummy.Allocated)
+	return len(buf)
+}
+
+func builtinArgs(xs []int, m map[string]int) []int {
+	xs = append(xs, dummy.Appended)
+	copy(xs, dummy.Copied)
+	delete(m, dummy.Deleted)
+	return xs
+}
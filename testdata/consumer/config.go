@@ -0,0 +1,11 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+type config struct {
+	timeout int
+}
+
+func newConfig() *config {
+	return &config{timeout: dummy.DefaultTimeout}
+}
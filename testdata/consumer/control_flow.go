@@ -0,0 +1,13 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func loop() {
+	if err := dummy.Setup(); err != nil {
+		return
+	}
+	if dummy.Enabled() {
+		for i := dummy.First; i < dummy.Max; i = dummy.Next(i) {
+		}
+	}
+}
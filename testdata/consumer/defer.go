@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func borrow() {
+	defer dummy.Pool.Release()
+}
@@ -0,0 +1,17 @@
+// consumer contains code that references dummy, used in tests.
+package consumer
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/launchdarkly-labs/refaudit/internal/dummy"
+)
+
+func sortInts(xs []int) {
+	sort.Slice(xs, dummy.Less)
+}
+
+func serve() {
+	http.HandleFunc("/", dummy.Handler)
+}
@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func init() {
+	dummy.Hook = func() {}
+}
@@ -0,0 +1,11 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func body(buf []byte) []byte {
+	return buf[dummy.HeaderLen:]
+}
+
+func at(buf []byte) byte {
+	return buf[dummy.Offset]
+}
@@ -0,0 +1,15 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+type greeter interface {
+	Greet() string
+}
+
+func greet(g greeter) string {
+	return g.Greet()
+}
+
+func greetDummy() string {
+	return greet(dummy.Greeter{})
+}
@@ -0,0 +1,13 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+var defaults = dummy.Options{Timeout: 5}
+
+func retrying() *dummy.Options {
+	return &dummy.Options{Retries: 3}
+}
+
+var named = map[string][]*dummy.Options{
+	"a": {{Name: "a"}},
+}
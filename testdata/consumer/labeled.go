@@ -0,0 +1,15 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func steps() {
+outer:
+	for {
+		for {
+			if dummy.Step() {
+				continue outer
+			}
+			break outer
+		}
+	}
+}
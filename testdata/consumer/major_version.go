@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy/v2"
+
+func callV2() {
+	dummy.ExportedFunction()
+}
@@ -0,0 +1,9 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+var statusText = map[int]string{}
+
+func okText() string {
+	return statusText[dummy.StatusOK]
+}
@@ -0,0 +1,8 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func serve() {
+	var s dummy.Server
+	s.Start()
+}
@@ -0,0 +1,19 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+type settings struct {
+	Outer dummy.Outer
+}
+
+type embedding struct {
+	*dummy.Outer
+}
+
+func depth(s *settings) int {
+	return s.Outer.Inner.Depth
+}
+
+func promoted(e embedding) string {
+	return e.Label
+}
@@ -0,0 +1,12 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func drain(r dummy.Reader) error {
+	buf := make([]byte, 8)
+	for {
+		if _, err := r.Read(buf); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+var handlers = map[string]func(){
+	"ping": dummy.Ping,
+}
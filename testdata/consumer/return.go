@@ -0,0 +1,15 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func computed(x int) int {
+	return dummy.Compute(x)
+}
+
+func fallback() int {
+	return dummy.Fallback
+}
+
+func countedOnce() int {
+	return dummy.Counted()
+}
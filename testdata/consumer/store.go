@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func load(s dummy.Store) string {
+	return s.Get("key")
+}
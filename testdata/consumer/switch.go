@@ -0,0 +1,15 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func describe() string {
+	switch dummy.CurrentMode() {
+	case 0:
+		return "slow"
+	}
+	switch v := dummy.Value().(type) {
+	case string:
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,14 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+var elems []dummy.Elem
+
+var lookup map[dummy.Key]dummy.Val
+
+func drain(msgs <-chan dummy.Msg) {
+	for range msgs {
+	}
+}
+
+var handler func(dummy.Arg) (*dummy.Result, error)
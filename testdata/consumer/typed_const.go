@@ -0,0 +1,6 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+// Mode re-exposes a dummy mode as a typed constant.
+const Mode dummy.Mode = dummy.ModeFast
@@ -0,0 +1,14 @@
+package containers
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+// countStructs only refers to the type in a type assertion.
+func countStructs() int {
+	n := 0
+	for _, v := range values {
+		if _, ok := v.(dummy.ExportedStruct); ok {
+			n++
+		}
+	}
+	return n
+}
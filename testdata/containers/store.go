@@ -0,0 +1,6 @@
+package containers
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+// values holds anything, including exported types from another package.
+var values = []interface{}{dummy.ExportedStruct{}, 1, "two"}
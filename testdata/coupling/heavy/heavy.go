@@ -0,0 +1,15 @@
+// heavy references several dummy exports, used in tests.
+package heavy
+
+import (
+	"sort"
+
+	"github.com/launchdarkly-labs/refaudit/internal/dummy"
+)
+
+func call(xs []int) {
+	dummy.ExportedFunction()
+	sort.Slice(xs, dummy.Less)
+	_ = dummy.ExportedVariable
+	_ = dummy.ExportedVariable
+}
@@ -0,0 +1,8 @@
+// light references a single dummy export, used in tests.
+package light
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func call() {
+	dummy.ExportedFunction()
+}
@@ -0,0 +1,20 @@
+// docs contains exports that are only mentioned in documentation.
+package docs
+
+// Documented is only used by an Example function.
+func Documented() {}
+
+// Commented is only used in its own doc comment:
+//
+//	docs.Commented()
+func Commented() {}
+
+// Fenced is only used in a fenced code block:
+//
+// ```go
+// docs.Fenced()
+// ```
+func Fenced() {}
+
+// Undocumented is never used, and its doc comment doesn't count: docs.Undocumented.
+func Undocumented() {}
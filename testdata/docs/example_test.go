@@ -0,0 +1,7 @@
+package docs_test
+
+import "github.com/launchdarkly-labs/refaudit/testdata/docs"
+
+func ExampleDocumented() {
+	docs.Documented()
+}
@@ -0,0 +1,17 @@
+// dotimport uses an export through a dot import.
+package dotimport
+
+import (
+	"fmt"
+
+	. "github.com/launchdarkly-labs/refaudit/internal/dummy"
+)
+
+type Local struct {
+	Name string
+}
+
+func use() {
+	Dotted()
+	fmt.Println(Local{Name: "local"}.Name)
+}
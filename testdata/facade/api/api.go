@@ -0,0 +1,8 @@
+// api is a facade over impl.
+package api
+
+import "github.com/launchdarkly-labs/refaudit/testdata/facade/internal/impl"
+
+func Do() {
+	impl.Do()
+}
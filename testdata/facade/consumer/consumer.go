@@ -0,0 +1,7 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/testdata/facade/api"
+
+func use() {
+	api.Do()
+}
@@ -0,0 +1,6 @@
+// impl is only exposed through the api facade, used in tests.
+package impl
+
+func Do() {}
+
+func Unwrapped() {}
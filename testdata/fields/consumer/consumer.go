@@ -0,0 +1,19 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/testdata/fields/lib"
+
+var name = lib.Config{}.Name
+
+func timeout(cfg *lib.Config) int {
+	return cfg.Timeout
+}
+
+func host() string {
+	var c lib.Config
+	return c.Host
+}
+
+func port() int {
+	c := &lib.Config{}
+	return c.Port
+}
@@ -0,0 +1,11 @@
+// lib has a struct with used and unused fields, used in tests.
+package lib
+
+type Config struct {
+	Timeout int
+	Retries int
+	Host    string
+	Port    int
+	Name    string
+	secret  int
+}
@@ -0,0 +1,4 @@
+// generated has generated and hand written exports, used in tests.
+package generated
+
+func API() {}
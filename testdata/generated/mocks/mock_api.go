@@ -0,0 +1,3 @@
+package mocks
+
+func MockAPI() {}
@@ -0,0 +1,8 @@
+package generics
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+// Set is only constrained by the dummy type, it never uses it otherwise.
+type Set[T dummy.Ordered] struct {
+	items []T
+}
@@ -0,0 +1,13 @@
+package generics
+
+import (
+	"slices"
+
+	"github.com/launchdarkly-labs/refaudit/internal/dummy"
+)
+
+func hasDefault(xs []int) bool {
+	return slices.Contains(xs, dummy.Default)
+}
+
+var hottest = slices.Max[[]dummy.Celsius]
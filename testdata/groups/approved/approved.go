@@ -0,0 +1,6 @@
+// approved is in the approved consumer group, used in tests.
+package approved
+
+import "github.com/launchdarkly-labs/refaudit/testdata/groups/lib"
+
+func run() { lib.Shipped() }
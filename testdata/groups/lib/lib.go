@@ -0,0 +1,8 @@
+// lib is used by consumers in different groups, used in tests.
+package lib
+
+func Shipped() {}
+
+func Experimental() {}
+
+func Orphan() {}
@@ -0,0 +1,9 @@
+// other isn't in the approved consumer group, used in tests.
+package other
+
+import "github.com/launchdarkly-labs/refaudit/testdata/groups/lib"
+
+func run() {
+	lib.Shipped()
+	lib.Experimental()
+}
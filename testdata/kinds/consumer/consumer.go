@@ -0,0 +1,8 @@
+package consumer
+
+import "github.com/launchdarkly-labs/refaudit/testdata/kinds"
+
+func use() kinds.UsedType {
+	kinds.UsedFunc()
+	return kinds.UsedType{}
+}
@@ -0,0 +1,8 @@
+// kinds has an export of each kind, used in tests.
+package kinds
+
+func UsedFunc() {}
+
+type UsedType struct{}
+
+var UnusedVar = 1
@@ -0,0 +1,4 @@
+// loaderror declares two packages in one directory, which fails to load.
+package loaderror
+
+var Exported = 1
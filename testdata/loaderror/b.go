@@ -0,0 +1,3 @@
+package other
+
+var AlsoExported = 1
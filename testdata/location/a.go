@@ -0,0 +1,6 @@
+// location has exports spread over several files, used in tests.
+package location
+
+func Zed() {}
+
+var B, A = 1, 2
@@ -0,0 +1,3 @@
+package location
+
+func Alpha() {}
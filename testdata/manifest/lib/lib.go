@@ -0,0 +1,10 @@
+// lib has exports that are only named in a plugin manifest.
+package lib
+
+func Handler() {}
+
+type Plugin struct{}
+
+func (Plugin) Serve() {}
+
+func Unlisted() {}
@@ -0,0 +1,12 @@
+// missingimport imports a package that doesn't exist, so it only fails to load
+// when it's type-checked.
+package missingimport
+
+import (
+	"github.com/launchdarkly-labs/refaudit/internal/dummy"
+	"github.com/launchdarkly-labs/refaudit/internal/missing"
+)
+
+var _ = dummy.Start
+
+var _ = missing.Gone
@@ -0,0 +1,8 @@
+// one is a module that is audited, used in tests.
+package one
+
+import "fmt"
+
+func One() {
+	fmt.Println()
+}
@@ -0,0 +1,8 @@
+// two is a module that is excluded, used in tests.
+package two
+
+import "strings"
+
+func Two() string {
+	return strings.ToUpper("")
+}
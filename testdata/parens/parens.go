@@ -0,0 +1,10 @@
+// parens selects from parenthesized packages. The parser accepts this even
+// though the type checker doesn't.
+package parens
+
+import "fmt"
+
+func print() {
+	(fmt).Println("hi")
+	(fmt).Sprint("hi")
+}
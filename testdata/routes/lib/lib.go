@@ -0,0 +1,8 @@
+// lib has handlers that are only routed to by name, used in tests.
+package lib
+
+type Service struct{}
+
+func (Service) GetUser() {}
+
+func (Service) DeleteUser() {}
@@ -0,0 +1,11 @@
+// server routes to lib's handlers by name, used in tests.
+package server
+
+import "github.com/launchdarkly-labs/refaudit/testdata/routes/lib"
+
+var service lib.Service
+
+var routes = map[string]string{
+	"users": "/api/Service.GetUser",
+	"home":  "/index.html",
+}
@@ -0,0 +1,9 @@
+// app shadows the lib package with a local variable, used in tests.
+package app
+
+import "github.com/launchdarkly-labs/refaudit/testdata/shadow/lib"
+
+func name() string {
+	lib := lib.Load()
+	return lib.Name
+}
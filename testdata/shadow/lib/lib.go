@@ -0,0 +1,11 @@
+// lib's Name is only ever selected from a local variable named lib, used in
+// tests.
+package lib
+
+type Config struct {
+	Name string
+}
+
+var Name = "lib"
+
+func Load() Config { return Config{Name: Name} }
@@ -0,0 +1,8 @@
+package billing
+
+import "github.com/launchdarkly-labs/refaudit/testdata/simulate/lib"
+
+func charge() {
+	lib.Shared()
+	lib.Solo()
+}
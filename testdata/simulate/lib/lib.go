@@ -0,0 +1,6 @@
+// lib is used by two consumers, one of which is the only user of Solo.
+package lib
+
+func Shared() {}
+
+func Solo() {}
@@ -0,0 +1,7 @@
+package web
+
+import "github.com/launchdarkly-labs/refaudit/testdata/simulate/lib"
+
+func serve() {
+	lib.Shared()
+}
@@ -0,0 +1,10 @@
+//go:build integration
+// +build integration
+
+package tagged
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func integration() {
+	dummy.ExportedFunction()
+}
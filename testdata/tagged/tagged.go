@@ -0,0 +1,2 @@
+// tagged has consumers that are only built with the integration tag, used in tests.
+package tagged
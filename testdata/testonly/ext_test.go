@@ -0,0 +1,11 @@
+package testonly_test
+
+import (
+	"testing"
+
+	"github.com/launchdarkly-labs/refaudit/testdata/testonly"
+)
+
+func TestExternallyTested(t *testing.T) {
+	testonly.ExternallyTested()
+}
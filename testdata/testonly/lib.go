@@ -0,0 +1,8 @@
+// testonly has exports that are only used by its tests, used in tests.
+package testonly
+
+func Used() {}
+
+func TestedOnly() {}
+
+func ExternallyTested() {}
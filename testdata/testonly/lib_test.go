@@ -0,0 +1,7 @@
+package testonly
+
+import "testing"
+
+func TestTestedOnly(t *testing.T) {
+	TestedOnly()
+}
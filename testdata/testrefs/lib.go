@@ -0,0 +1,7 @@
+// testrefs has exports referenced by production code and by tests, used in
+// tests.
+package testrefs
+
+func Prod() {}
+
+func TestedOnly() {}
@@ -0,0 +1,7 @@
+package use
+
+import "github.com/launchdarkly-labs/refaudit/testdata/testrefs"
+
+func use() {
+	testrefs.Prod()
+}
@@ -0,0 +1,12 @@
+package use
+
+import (
+	"testing"
+
+	"github.com/launchdarkly-labs/refaudit/testdata/testrefs"
+)
+
+func TestUse(t *testing.T) {
+	testrefs.Prod()
+	testrefs.TestedOnly()
+}
@@ -0,0 +1,3 @@
+package testvariants_test
+
+func ExternalHelper() {}
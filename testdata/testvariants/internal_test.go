@@ -0,0 +1,3 @@
+package testvariants
+
+func InternalHelper() {}
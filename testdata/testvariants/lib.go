@@ -0,0 +1,4 @@
+// testvariants has exports in its tests, used in tests.
+package testvariants
+
+func Lib() {}
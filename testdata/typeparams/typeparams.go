@@ -0,0 +1,11 @@
+// typeparams references exports only as type parameters and type arguments.
+// It is only parsed, since the module predates generics.
+package typeparams
+
+import "github.com/launchdarkly-labs/refaudit/internal/dummy"
+
+func ping[T dummy.Constraint](t T) {
+	t.Ping()
+}
+
+var _ = ping[dummy.TypeArg]
@@ -0,0 +1,7 @@
+package consumer
+
+import "reflect"
+
+func call(plugin interface{}) {
+	reflect.ValueOf(plugin).MethodByName("Dynamic").Call(nil)
+}
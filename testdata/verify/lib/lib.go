@@ -0,0 +1,10 @@
+// lib has an export that is only used by reflection, used in tests.
+package lib
+
+type Plugin struct{}
+
+func (Plugin) Dynamic() {}
+
+func Dead() {}
+
+func Dynamic() {}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"strings"
+	"sync"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+)
+
+// findTestUses returns the exports in dirs that are referenced by the test
+// files of their own package, whether internal or external tests.
+func findTestUses(ctx context.Context, dirs []string, excluding []string) (map[string]interface{}, error) {
+	uses := make(map[string]interface{})
+	pkgs := newPkgResolver()
+	var mu sync.Mutex
+
+	fs := token.NewFileSet()
+	err := runOnFiles(ctx, dirs, excluding, func(file string) error {
+		if !isTestFile(file) {
+			return nil
+		}
+		f, err := parser.ParseFile(fs, file, nil, parser.AllErrors)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", file, err)
+		}
+		own := strings.TrimSuffix(pkgs.pkgPath(file), "_test")
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// internal tests refer to their package's exports by name, and the
+		// parser leaves names declared in other files unresolved
+		if !strings.HasSuffix(f.Name.Name, "_test") {
+			for _, ident := range f.Unresolved {
+				if ident.IsExported() {
+					uses[own+"."+ident.Name] = exists
+				}
+			}
+		}
+		for symbol := range symbols.FindRefs(f) {
+			if rest := strings.TrimPrefix(symbol, own+"."); rest != symbol && !strings.Contains(rest, "/") {
+				uses[symbol] = exists
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find test uses: %w", err)
+	}
+	return uses, nil
+}
+
+// dropTestRefs removes the references from test files, and returns the symbols
+// that only test files referenced.
+func dropTestRefs(refs map[string]map[string]int) map[string]interface{} {
+	testOnly := make(map[string]interface{})
+	for symbol, files := range refs {
+		for file := range files {
+			if isTestFile(file) {
+				delete(files, file)
+			}
+		}
+		if len(files) == 0 {
+			delete(refs, symbol)
+			testOnly[symbol] = exists
+		}
+	}
+	return testOnly
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// TrendRecord is a line of a --trend-file.
+type TrendRecord struct {
+	Time time.Time `json:"time"`
+	Summary
+}
+
+// summarize counts the exports in rpt.
+func summarize(rpt Report) Summary {
+	return Summary{
+		Exported:   len(rpt.Exported),
+		Referenced: len(rpt.Exported) - len(rpt.UnusedExports),
+		Unused:     len(rpt.UnusedExports),
+	}
+}
+
+// appendTrend appends a line with the current time and rpt's counts to file,
+// creating it if needed. The line is written with a single append, so runs
+// sharing a file don't corrupt each other's lines.
+func appendTrend(file string, rpt Report, now time.Time) error {
+	line, err := json.Marshal(TrendRecord{now.UTC(), summarize(rpt)})
+	if err != nil {
+		return fmt.Errorf("failed to marshal trend: %w", err)
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("could not open trend file %s: %w", file, err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("could not append to trend file %s: %w", file, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/launchdarkly-labs/refaudit/symbols"
+	"golang.org/x/tools/go/packages"
+)
+
+const typedLoadMode = packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles |
+	packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedModule
+
+// typedPackage is a type-checked package from one of the audited directories.
+type typedPackage struct {
+	pkg   *packages.Package
+	types *types.Package
+	info  *types.Info
+}
+
+// typedLoad is the result of type-checking the packages under one directory.
+type typedLoad struct {
+	fs    *token.FileSet
+	roots []typedPackage
+	// every package that was type-checked, including dependencies, by ID
+	checked map[string]*types.Package
+	pkgs    map[string]*packages.Package
+	// type info for the packages in the audited directory, by ID
+	infos map[string]*types.Info
+	// errors from loading or type-checking any of the packages, as
+	// "pkgPath: error" -> exists
+	diagnostics map[string]interface{}
+}
+
+// loadTyped loads and type-checks every package under dir, skipping packages
+// in excluded directories, with dir's build tags. Only the type info needed to
+// credit the kinds of exports in kinds is recorded, or all of it if kinds is
+// nil. Type-checking is done from source rather than by
+// x/tools, whose export data reader is tied to the go toolchain version.
+func loadTyped(ctx context.Context, dir string, excluding []string, tags buildTags, kinds map[symbols.Kind]interface{}) (typedLoad, error) {
+	fs := token.NewFileSet()
+	cfg := &packages.Config{Context: ctx, Mode: typedLoadMode, Tests: true, Dir: dir, Fset: fs, BuildFlags: tags.buildFlags(dir)}
+	start := time.Now()
+	pkgs, err := packages.Load(cfg, "./...")
+	if err != nil {
+		return typedLoad{}, fmt.Errorf("could not load packages in %s: %w", dir, err)
+	}
+	recordLoad(ctx, filepath.Join(dir, "..."), time.Since(start))
+
+	tl := typedLoad{
+		fs:      fs,
+		checked: map[string]*types.Package{"unsafe": types.Unsafe},
+		pkgs:    make(map[string]*packages.Package),
+		infos:   make(map[string]*types.Info),
+
+		diagnostics: make(map[string]interface{}),
+	}
+	roots := []*packages.Package{}
+	for _, pkg := range pkgs {
+		// skip the generated test main packages
+		if strings.HasSuffix(pkg.ID, ".test") || len(pkg.GoFiles) == 0 {
+			continue
+		}
+		if isExcluded(filepath.Dir(pkg.GoFiles[0]), excluding) {
+			continue
+		}
+		roots = append(roots, pkg)
+		tl.infos[pkg.ID] = typedInfo(kinds)
+	}
+	for _, pkg := range roots {
+		tl.roots = append(tl.roots, typedPackage{pkg, tl.check(pkg), tl.infos[pkg.ID]})
+	}
+	return tl, nil
+}
+
+// typedInfo returns empty type info to record, for crediting the kinds of exports
+// in kinds, or all of them if kinds is nil. Methods and fields are attributed to
+// their types, so selections are only recorded for types, and uses of other
+// identifiers are only recorded for the other kinds. Both grow with every
+// selector in the audited packages, so leaving them out saves a lot.
+func typedInfo(kinds map[symbols.Kind]interface{}) *types.Info {
+	wants := func(kind symbols.Kind) bool {
+		_, ok := kinds[kind]
+		return kinds == nil || ok
+	}
+	info := &types.Info{}
+	if wants(symbols.Type) || wants(symbols.Field) || wants(symbols.InterfaceMethod) {
+		info.Selections = make(map[*ast.SelectorExpr]*types.Selection)
+	}
+	if wants(symbols.Func) || wants(symbols.Var) || wants(symbols.Const) {
+		info.Uses = make(map[*ast.Ident]types.Object)
+	}
+	return info
+}
+
+// check type-checks pkg after its imports. Type errors don't stop it; whatever
+// could be resolved is still recorded, and the errors are kept as diagnostics.
+// Type errors in a package that failed to load mostly repeat why, so only its
+// load errors are kept.
+func (tl typedLoad) check(pkg *packages.Package) *types.Package {
+	if tp, ok := tl.checked[pkg.ID]; ok {
+		return tp
+	}
+	for _, imp := range pkg.Imports {
+		tl.check(imp)
+	}
+	conf := types.Config{
+		Importer: importerFunc(func(path string) (*types.Package, error) {
+			imp, ok := pkg.Imports[path]
+			if !ok {
+				return nil, fmt.Errorf("%s is not imported by %s", path, pkg.PkgPath)
+			}
+			return tl.checked[imp.ID], nil
+		}),
+		Sizes:       types.SizesFor("gc", build.Default.GOARCH),
+		FakeImportC: true,
+		Error: func(err error) {
+			if len(pkg.Errors) == 0 {
+				tl.diagnostics[fmt.Sprintf("%s: %v", pkg.PkgPath, err)] = exists
+			}
+		},
+	}
+	for _, pkgErr := range pkg.Errors {
+		tl.diagnostics[fmt.Sprintf("%s: %v", pkg.PkgPath, pkgErr)] = exists
+	}
+	tp, _ := conf.Check(pkg.PkgPath, tl.fs, pkg.Syntax, tl.infos[pkg.ID])
+	tl.checked[pkg.ID] = tp
+	tl.pkgs[pkg.ID] = pkg
+	return tp
+}
+
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) {
+	return f(path)
+}
+
+// isExcluded reports whether path is one of the excluded paths or inside one.
+// Paths are compared cleaned, so trailing separators and dot elements don't
+// matter.
+func isExcluded(path string, excluding []string) bool {
+	path = filepath.Clean(path)
+	for _, ex := range excluding {
+		ex = filepath.Clean(ex)
+		if path == ex || strings.HasPrefix(path, strings.TrimSuffix(ex, fsep)+fsep) {
+			return true
+		}
+	}
+	return false
+}
+
+// typedUses is what type-checking the imports found.
+type typedUses struct {
+	// methods that could be called through an interface, as "pkg.Type.Method"
+	interfaces map[string]interface{}
+	// methods called directly and fields selected, as "pkg.Type.Name" -> files
+	// using them -> uses
+	methods map[string]map[string]int
+	// package-level exports of other packages, and fields that are keys in
+	// literals, resolved to what they refer to -> files using them -> uses
+	refs map[string]map[string]int
+	// files whose references are in refs -> exists
+	files map[string]interface{}
+	// errors from loading or type-checking the packages, sorted
+	diagnostics []string
+}
+
+// replace swaps the references refs has from the files uses resolved for the
+// ones uses found in them.
+func (uses typedUses) replace(refs map[string]map[string]int) {
+	for symbol, files := range refs {
+		for file := range files {
+			if _, ok := uses.files[file]; ok {
+				delete(files, file)
+			}
+		}
+		if len(files) == 0 {
+			delete(refs, symbol)
+		}
+	}
+	for symbol, files := range uses.refs {
+		for file, n := range files {
+			addRef(refs, symbol, file, n)
+		}
+	}
+}
+
+// findTypedUses type-checks the packages in to and returns the methods they
+// call and the fields they select, at every level of a chain like
+// cfg.Inner.Field. For methods called through an interface, any non-standard library type
+// that implements the interface is credited, so this over-approximates. Methods
+// and fields are only found if kinds is nil or includes types or fields. If it
+// includes funcs, vars or consts, every other reference is resolved too, so
+// that a local variable named like a package isn't mistaken for it. Files in
+// several variants of a package, like its test variant, are only counted once,
+// and files in excluded modules not at all.
+func findTypedUses(ctx context.Context, to []string, excludeTo []string, tags buildTags, excludeModules moduleFilter, kinds map[symbols.Kind]interface{}) (typedUses, error) {
+	uses := typedUses{make(map[string]interface{}), make(map[string]map[string]int), make(map[string]map[string]int), make(map[string]interface{}), []string{}}
+	// files seen in an earlier package -> exists
+	seenFiles := make(map[string]interface{})
+	for _, dir := range to {
+		tl, err := loadTyped(ctx, dir, excludeTo, tags, kinds)
+		if err != nil {
+			return typedUses{}, fmt.Errorf("failed to find typed uses: %w", err)
+		}
+		for d := range tl.diagnostics {
+			uses.diagnostics = sortedInsert(uses.diagnostics, d)
+		}
+		candidates := tl.namedTypes()
+		type ifaceMethod struct {
+			iface *types.Interface
+			name  string
+		}
+		seen := make(map[ifaceMethod]interface{})
+		owners := tl.fieldOwners()
+		for _, root := range tl.roots {
+			// the files this package is the first to have
+			owned := make(map[string]interface{})
+			// identifiers that are keys in literals, like Timeout in
+			// pkg.Config{Timeout: 5}
+			keys := make(map[*ast.Ident]interface{})
+			for _, f := range root.pkg.Syntax {
+				file := tl.fs.Position(f.Pos()).Filename
+				if _, ok := seenFiles[file]; ok || excludeModules.skip(file) {
+					continue
+				}
+				seenFiles[file] = exists
+				owned[file] = exists
+				ast.Inspect(f, func(n ast.Node) bool {
+					if kv, ok := n.(*ast.KeyValueExpr); ok {
+						if key, ok := kv.Key.(*ast.Ident); ok {
+							keys[key] = exists
+						}
+					}
+					return true
+				})
+			}
+			isOwned := func(pos token.Pos) bool {
+				_, ok := owned[tl.fs.Position(pos).Filename]
+				return ok
+			}
+			if root.info.Uses != nil {
+				for file := range owned {
+					uses.files[file] = exists
+				}
+				for ident, obj := range root.info.Uses {
+					if !isOwned(ident.Pos()) || obj.Pkg() == nil || !obj.Exported() {
+						continue
+					}
+					symbol := ""
+					if owner, ok := owners[obj]; ok {
+						// other uses of fields are selections
+						if _, ok := keys[ident]; ok {
+							symbol = owner
+						}
+					} else if obj.Parent() == obj.Pkg().Scope() && obj.Pkg().Path() != root.pkg.PkgPath {
+						symbol = obj.Pkg().Path() + "." + obj.Name()
+					}
+					if symbol != "" {
+						addRef(uses.refs, symbol, tl.fs.Position(ident.Pos()).Filename, 1)
+					}
+				}
+			}
+			for expr, sel := range root.info.Selections {
+				if !isOwned(expr.Pos()) {
+					continue
+				}
+				if sel.Kind() == types.FieldVal {
+					for _, symbol := range fieldPath(sel) {
+						addRef(uses.methods, symbol, tl.fs.Position(expr.Pos()).Filename, 1)
+					}
+					continue
+				}
+				if !sel.Obj().Exported() {
+					continue
+				}
+				if !types.IsInterface(sel.Recv()) {
+					// attribute promoted methods to the type that declares them
+					if named := receiverType(sel.Obj()); named != nil && named.Obj().Pkg() != nil {
+						obj := named.Obj()
+						addRef(uses.methods, obj.Pkg().Path()+"."+obj.Name()+"."+sel.Obj().Name(), tl.fs.Position(expr.Pos()).Filename, 1)
+					}
+					continue
+				}
+				// credit the interface that declares the method, for
+				// --interface-granularity method
+				if named := receiverType(sel.Obj()); named != nil && named.Obj().Pkg() != nil {
+					obj := named.Obj()
+					addRef(uses.methods, obj.Pkg().Path()+"."+obj.Name()+"."+sel.Obj().Name(), tl.fs.Position(expr.Pos()).Filename, 1)
+				}
+				iface, ok := sel.Recv().Underlying().(*types.Interface)
+				if !ok {
+					continue
+				}
+				if _, ok := seen[ifaceMethod{iface, sel.Obj().Name()}]; ok {
+					continue
+				}
+				seen[ifaceMethod{iface, sel.Obj().Name()}] = exists
+				for _, named := range candidates {
+					if types.Implements(named, iface) || types.Implements(types.NewPointer(named), iface) {
+						obj := named.Obj()
+						uses.interfaces[obj.Pkg().Path()+"."+obj.Name()+"."+sel.Obj().Name()] = exists
+					}
+				}
+			}
+		}
+	}
+	return uses, nil
+}
+
+// fieldPath returns the exported fields of exported struct types that the field
+// selection sel goes through, as "pkg.Type.Field". A promoted field is reached
+// through the embedded fields before it, so those count too.
+func fieldPath(sel *types.Selection) []string {
+	path := []string{}
+	typ := sel.Recv()
+	for _, i := range sel.Index() {
+		if ptr, ok := typ.(*types.Pointer); ok {
+			typ = ptr.Elem()
+		}
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			break
+		}
+		field := st.Field(i)
+		if named, ok := typ.(*types.Named); ok && field.Exported() {
+			if obj := named.Obj(); obj.Exported() && obj.Pkg() != nil {
+				path = append(path, obj.Pkg().Path()+"."+obj.Name()+"."+field.Name())
+			}
+		}
+		typ = field.Type()
+	}
+	return path
+}
+
+// fieldOwners maps the exported fields of the exported struct types in every
+// package that was type-checked to their symbols, as "pkg.Type.Field".
+func (tl typedLoad) fieldOwners() map[types.Object]string {
+	owners := make(map[types.Object]string)
+	for _, tp := range tl.checked {
+		if tp == nil {
+			continue
+		}
+		scope := tp.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !obj.Exported() {
+				continue
+			}
+			st, ok := obj.Type().Underlying().(*types.Struct)
+			if !ok {
+				continue
+			}
+			for i := 0; i < st.NumFields(); i++ {
+				if field := st.Field(i); field.Exported() {
+					owners[field] = tp.Path() + "." + obj.Name() + "." + field.Name()
+				}
+			}
+		}
+	}
+	return owners
+}
+
+// receiverType returns the named type that declares the method obj, with
+// pointers dereferenced, or nil if it isn't a method of a named type.
+func receiverType(obj types.Object) *types.Named {
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return nil
+	}
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return nil
+	}
+	typ := recv.Type()
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, _ := typ.(*types.Named)
+	return named
+}
+
+// namedTypes returns the exported, concrete, package-level types declared
+// outside of the standard library.
+func (tl typedLoad) namedTypes() []*types.Named {
+	named := []*types.Named{}
+	for id, tp := range tl.checked {
+		pkg, ok := tl.pkgs[id]
+		if !ok || pkg.Module == nil || tp == nil {
+			continue
+		}
+		scope := tp.Scope()
+		for _, name := range scope.Names() {
+			obj, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || !obj.Exported() {
+				continue
+			}
+			if n, ok := obj.Type().(*types.Named); ok && !types.IsInterface(n) {
+				named = append(named, n)
+			}
+		}
+	}
+	return named
+}
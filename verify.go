@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go/token"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// identifier matches anything that could be a go identifier.
+var identifier = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// verifyUnused searches the text of every file in to for the bare names of the
+// unused exports, and returns the ones whose name appears anywhere other than
+// the file they are declared in. This catches references the syntax analysis
+// can't see, like reflection and files excluded by build tags.
+func verifyUnused(ctx context.Context, to []string, excludeTo []string, unused []string, positions map[string]token.Position) (map[string]interface{}, error) {
+	// bare name -> symbols with that name
+	names := make(map[string][]string)
+	for _, symbol := range unused {
+		name := symbol[strings.LastIndex(symbol, ".")+1:]
+		names[name] = append(names[name], symbol)
+	}
+
+	found := make(map[string]interface{})
+	// guards found, which fn fills from several goroutines
+	var mu sync.Mutex
+	err := runOnFiles(ctx, to, excludeTo, func(file string) error {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("could not read %s: %w", file, err)
+		}
+		// scan without the lock, so files are scanned in parallel
+		var inFile []string
+		for _, word := range identifier.FindAllString(string(b), -1) {
+			for _, symbol := range names[word] {
+				if positions[symbol].Filename != file {
+					inFile = append(inFile, symbol)
+				}
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		for _, symbol := range inFile {
+			found[symbol] = exists
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify unused exports: %w", err)
+	}
+	return found, nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.date=...".
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// writeVersion writes the version, commit and build date of this build. The
+// version falls back to the module version from the build info, which go
+// install sets.
+func writeVersion(w io.Writer) error {
+	v := version
+	if v == "" {
+		v = "unknown"
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			v = info.Main.Version
+		}
+	}
+	orUnknown := func(s string) string {
+		if s == "" {
+			return "unknown"
+		}
+		return s
+	}
+	_, err := fmt.Fprintf(w, "refaudit %s\ncommit: %s\nbuilt: %s\n", v, orUnknown(commit), orUnknown(date))
+	return err
+}